@@ -0,0 +1,34 @@
+package collector
+
+import "time"
+
+// idleCollected are the sub-collectors that still run for an idle device
+// with -collector.active-only, so the idleness itself stays visible.
+var idleCollected = map[string]bool{
+    "utilization": true,
+    "power":       true,
+    "temperature": true,
+}
+
+// deviceIdle reports whether dev has had 0% utilization and no compute
+// processes for at least -collector.idle-grace. c.idleSince tracks when each
+// device went idle. A failed check counts as busy.
+func (c *Collector) deviceIdle(dev Device, uuid string, now time.Time) bool {
+    utilizationGPU, _, err := dev.UtilizationRates()
+    busy := err != nil || utilizationGPU > 0
+    if r, ok := dev.(processReader); ok && !busy {
+        pids, _, err := r.ComputeRunningProcesses()
+        busy = err != nil || len(pids) > 0
+    }
+    if busy {
+        delete(c.idleSince, uuid)
+        return false
+    }
+
+    since, ok := c.idleSince[uuid]
+    if !ok {
+        since = now
+        c.idleSince[uuid] = now
+    }
+    return now.Sub(since) >= *idleGrace
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// pciDevicesPath is where sysfs lists PCI devices by bus ID.
+const pciDevicesPath = "/sys/bus/pci/devices"
+
+// pciBusIDReader is implemented by devices that can report their PCI bus ID
+// (nvmlDeviceGetPciInfo), e.g. "00000000:3B:00.0".
+type pciBusIDReader interface {
+    PciBusID() (string, error)
+}
+
+// sysfsBusID converts an NVML bus ID to the form sysfs uses: lower case with
+// a four digit domain, e.g. "0000:3b:00.0".
+func sysfsBusID(busID string) string {
+    busID = strings.ToLower(busID)
+    if i := strings.Index(busID, ":"); i > 4 {
+        busID = busID[i-4:]
+    }
+    return busID
+}
+
+// readAERTotal returns the TOTAL_ERR_* line of a sysfs AER counter file such
+// as aer_dev_correctable.
+func readAERTotal(path string) (uint64, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) == 2 && strings.HasPrefix(fields[0], "TOTAL_ERR_") {
+            return strconv.ParseUint(fields[1], 10, 64)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return 0, err
+    }
+    return 0, fmt.Errorf("%s: no TOTAL_ERR_ line", path)
+}
+
+// collectAER exports the PCIe Advanced Error Reporting counters the kernel
+// keeps for the device. Kernels without AER support, or containers without
+// /sys, don't have the files; that isn't an error.
+func (c *Collector) collectAER(dev Device, lv []string) error {
+    uuid, err := dev.UUID()
+    if err != nil {
+        return err
+    }
+    busID := c.staticInfo(dev, uuid).pciBusID
+    if busID == "" {
+        return nil
+    }
+    dir := filepath.Join(pciDevicesPath, sysfsBusID(busID))
+
+    var errs firstError
+    correctable, err := readAERTotal(filepath.Join(dir, "aer_dev_correctable"))
+    if err == nil {
+        c.setAERTotal(c.pcieAERCorrectable, lv, correctable)
+    } else if !os.IsNotExist(err) {
+        c.countError("aer_dev_correctable", err)
+        errs.record(err)
+    }
+
+    // The kernel splits uncorrectable errors into fatal and non-fatal ones.
+    var uncorrectable uint64
+    for _, name := range []string{"aer_dev_fatal", "aer_dev_nonfatal"} {
+        n, err := readAERTotal(filepath.Join(dir, name))
+        if err != nil {
+            if !os.IsNotExist(err) {
+                c.countError(name, err)
+                errs.record(err)
+            }
+            return errs.err
+        }
+        uncorrectable += n
+    }
+    c.setAERTotal(c.pcieAERUncorrectable, lv, uncorrectable)
+
+    return errs.err
+}
+
+// setAERTotal queues an AER total for this scrape. The kernel keeps the
+// count, so it is a counter, exported as a const metric rather than through
+// c.set; like the process series, -collector.zero-on-error doesn't keep it.
+func (c *Collector) setAERTotal(desc *prometheus.Desc, lv []string, total uint64) {
+    c.aerTotals = append(c.aerTotals, prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(total), lv...))
+    c.collected++
+}
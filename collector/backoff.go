@@ -0,0 +1,46 @@
+package collector
+
+import (
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// sweepTTL is how long the metrics of the previous sweep are served before
+// the devices are swept again: -collector.backoff-on-load while the busiest
+// GPU of that sweep was at or above -collector.backoff-threshold, otherwise
+// every scrape sweeps.
+func (c *Collector) sweepTTL() time.Duration {
+    if float64(c.maxUtilization) >= *backoffThreshold {
+        return *backoffOnLoad
+    }
+    return 0
+}
+
+// sweepCached reports whether a scrape at now is answered from the previous
+// sweep.
+func (c *Collector) sweepCached(now time.Time) bool {
+    ttl := c.sweepTTL()
+    return ttl > 0 && c.sweptMetrics != nil && now.Sub(c.lastSweep) < ttl
+}
+
+// sweepAndCache sweeps the devices into ch, keeping the metrics for the
+// scrapes sweepCached answers, and the utilization of the busiest GPU to
+// pick the next TTL.
+func (c *Collector) sweepAndCache(ch chan<- prometheus.Metric) {
+    c.maxUtilization = 0
+    metrics := make(chan prometheus.Metric)
+    done := make(chan struct{})
+    var swept []prometheus.Metric
+    go func() {
+        defer close(done)
+        for m := range metrics {
+            swept = append(swept, m)
+            ch <- m
+        }
+    }()
+    c.sweep(metrics)
+    close(metrics)
+    <-done
+    c.sweptMetrics, c.lastSweep = swept, time.Now()
+}
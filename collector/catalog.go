@@ -0,0 +1,130 @@
+package collector
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    dto "github.com/prometheus/client_model/go"
+)
+
+// catalogFlags are the flags that add or remove metrics. -print-metrics
+// flips each one in turn to find the metrics it gates.
+var catalogFlags = append([]string{
+    "enable-fanspeed",
+    "enable-powerlimits",
+    "enable-averagepowerusage",
+    "metrics.unified-clocks",
+}, validateFlags...)
+
+var descHelpPattern = regexp.MustCompile(`help: ("(?:[^"\\]|\\.)*")`)
+
+// catalogEntry describes one metric for -print-metrics.
+type catalogEntry struct {
+    name   string
+    typ    string
+    help   string
+    labels []string
+    needs  []string
+}
+
+// printMetricCatalog prints every metric the collector describes with its
+// type, help, labels and the flags it needs, using the mock device of
+// -validate-metrics, and returns the process exit code. The type and labels
+// of metrics the mock device can't produce (see validateExempt) are unknown.
+func printMetricCatalog(w io.Writer) int {
+    entries, err := metricCatalog()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return 1
+    }
+    for _, e := range entries {
+        fmt.Fprintf(w, "%s %s\n", e.name, e.typ)
+        fmt.Fprintf(w, "    %s\n", e.help)
+        if len(e.labels) > 0 {
+            fmt.Fprintf(w, "    labels: %s\n", strings.Join(e.labels, ", "))
+        }
+        if len(e.needs) > 0 {
+            fmt.Fprintf(w, "    needs: %s\n", strings.Join(e.needs, " "))
+        }
+        if reason := validateExempt[e.name]; reason != "" && e.typ == "unknown" {
+            fmt.Fprintf(w, "    not produced by the mock device: %s\n", reason)
+        }
+    }
+    return 0
+}
+
+func metricCatalog() ([]catalogEntry, error) {
+    if err := setValidateFlags(); err != nil {
+        return nil, err
+    }
+    c, base, err := gatherMock()
+    if err != nil {
+        return nil, err
+    }
+
+    all := make(map[string]*dto.MetricFamily, len(base))
+    for name, mf := range base {
+        all[name] = mf
+    }
+    needs := make(map[string][]string)
+    for _, name := range catalogFlags {
+        f := flags.Lookup(name)
+        orig, _ := strconv.ParseBool(f.Value.String())
+        if err := flags.Set(name, strconv.FormatBool(!orig)); err != nil {
+            return nil, fmt.Errorf("Setting -%s: %v", name, err)
+        }
+        _, flipped, err := gatherMock()
+        if err := flags.Set(name, strconv.FormatBool(orig)); err != nil {
+            return nil, fmt.Errorf("Setting -%s: %v", name, err)
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        for metric, mf := range flipped {
+            if base[metric] == nil {
+                needs[metric] = append(needs[metric], flagSetting(name, !orig))
+                all[metric] = mf
+            }
+        }
+        for metric := range base {
+            if flipped[metric] == nil {
+                needs[metric] = append(needs[metric], flagSetting(name, orig))
+            }
+        }
+    }
+
+    var entries []catalogEntry
+    for _, desc := range describe(c) {
+        m := descNamePattern.FindStringSubmatch(desc.String())
+        if m == nil {
+            return nil, fmt.Errorf("Can't parse %s", desc)
+        }
+        e := catalogEntry{name: m[1], typ: "unknown", needs: needs[m[1]]}
+        if h := descHelpPattern.FindStringSubmatch(desc.String()); h != nil {
+            e.help, _ = strconv.Unquote(h[1])
+        }
+        if mf := all[e.name]; mf != nil {
+            e.typ = strings.ToLower(mf.GetType().String())
+            for _, l := range mf.GetMetric()[0].GetLabel() {
+                e.labels = append(e.labels, l.GetName())
+            }
+        }
+        entries = append(entries, e)
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+    return entries, nil
+}
+
+// flagSetting formats the command line setting of a boolean flag.
+func flagSetting(name string, value bool) string {
+    if value {
+        return "-" + name
+    }
+    return "-" + name + "=false"
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+    "encoding/json"
+    "log"
+    "net/http"
+    "reflect"
+    "time"
+)
+
+// debugReadings are the interfaces whose methods /debug/nvml calls on every
+// device it implements them. Methods taking arguments other than a
+// time.Duration (the averaging window) are skipped.
+var debugReadings = []reflect.Type{
+    reflect.TypeOf((*Device)(nil)).Elem(),
+    reflect.TypeOf((*autoBoostReader)(nil)).Elem(),
+    reflect.TypeOf((*jpegUtilizationReader)(nil)).Elem(),
+    reflect.TypeOf((*ofaUtilizationReader)(nil)).Elem(),
+    reflect.TypeOf((*driverModelReader)(nil)).Elem(),
+    reflect.TypeOf((*vbiosVersionReader)(nil)).Elem(),
+    reflect.TypeOf((*pciBusIDReader)(nil)).Elem(),
+    reflect.TypeOf((*memoryTemperatureReader)(nil)).Elem(),
+    reflect.TypeOf((*eccErrorReader)(nil)).Elem(),
+    reflect.TypeOf((*remappedRowsReader)(nil)).Elem(),
+    reflect.TypeOf((*sramECCThresholdReader)(nil)).Elem(),
+    reflect.TypeOf((*processReader)(nil)).Elem(),
+    reflect.TypeOf((*supportedMemoryClocksReader)(nil)).Elem(),
+    reflect.TypeOf((*boardPartNumberReader)(nil)).Elem(),
+    reflect.TypeOf((*boardManufacturerReader)(nil)).Elem(),
+    reflect.TypeOf((*customerBoostClockReader)(nil)).Elem(),
+    reflect.TypeOf((*migModeReader)(nil)).Elem(),
+    reflect.TypeOf((*supportedThrottleReasonsReader)(nil)).Elem(),
+    reflect.TypeOf((*requestedClockReader)(nil)).Elem(),
+    reflect.TypeOf((*memoryInfoV2Reader)(nil)).Elem(),
+    reflect.TypeOf((*applicationsClocksReader)(nil)).Elem(),
+    reflect.TypeOf((*virtualizationModeReader)(nil)).Elem(),
+    reflect.TypeOf((*pcieByteCountersReader)(nil)).Elem(),
+    reflect.TypeOf((*inforomReader)(nil)).Elem(),
+}
+
+var (
+    durationType = reflect.TypeOf(time.Duration(0))
+    errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// debugCall is the raw outcome of one device call.
+type debugCall struct {
+    Values []interface{} `json:"values,omitempty"`
+    Error  string        `json:"error,omitempty"`
+}
+
+type debugDevice struct {
+    Index int                  `json:"index"`
+    Error string               `json:"error,omitempty"`
+    Calls map[string]debugCall `json:"calls,omitempty"`
+}
+
+type debugDump struct {
+    DeviceCount      uint          `json:"device_count"`
+    DeviceCountError string        `json:"device_count_error,omitempty"`
+    Devices          []debugDevice `json:"devices"`
+}
+
+// debugNVMLHandler serves a JSON dump of every device call result, errors
+// included, for support tickets.
+func debugNVMLHandler(c *Collector) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(c.debugDump()); err != nil {
+            log.Printf("Writing /debug/nvml response: %v", err)
+        }
+    })
+}
+
+func (c *Collector) debugDump() debugDump {
+    // Don't interleave with a scrape.
+    c.Lock()
+    defer c.Unlock()
+
+    var dump debugDump
+    numDevices, err := c.provider.DeviceCount()
+    if err != nil {
+        dump.DeviceCountError = err.Error()
+        return dump
+    }
+    dump.DeviceCount = numDevices
+
+    for i := 0; i < int(numDevices); i++ {
+        d := debugDevice{Index: i}
+        dev, err := c.provider.DeviceHandleByIndex(uint(i))
+        if err != nil {
+            d.Error = err.Error()
+        } else {
+            d.Calls = debugCalls(dev)
+        }
+        dump.Devices = append(dump.Devices, d)
+    }
+    return dump
+}
+
+func debugCalls(dev Device) map[string]debugCall {
+    calls := make(map[string]debugCall)
+    v := reflect.ValueOf(dev)
+    for _, iface := range debugReadings {
+        if !v.Type().Implements(iface) {
+            continue
+        }
+        for i := 0; i < iface.NumMethod(); i++ {
+            m := iface.Method(i)
+            call, ok := debugCallMethod(v.MethodByName(m.Name))
+            if ok {
+                calls[m.Name] = call
+            }
+        }
+    }
+    return calls
+}
+
+func debugCallMethod(m reflect.Value) (debugCall, bool) {
+    t := m.Type()
+    if t.NumOut() == 0 || t.Out(t.NumOut()-1) != errorType {
+        return debugCall{}, false
+    }
+    var args []reflect.Value
+    for i := 0; i < t.NumIn(); i++ {
+        if t.In(i) != durationType {
+            return debugCall{}, false
+        }
+        args = append(args, reflect.ValueOf(*averageDuration))
+    }
+
+    out := m.Call(args)
+    var call debugCall
+    if err, _ := out[len(out)-1].Interface().(error); err != nil {
+        call.Error = err.Error()
+        return call, true
+    }
+    for _, o := range out[:len(out)-1] {
+        call.Values = append(call.Values, o.Interface())
+    }
+    return call, true
+}
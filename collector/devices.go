@@ -0,0 +1,179 @@
+package collector
+
+import (
+    "log"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// indexedDevice is a device handle and the index it was enumerated at.
+type indexedDevice struct {
+    index int
+    dev   Device
+}
+
+// deviceHandles returns the handles of the first numDevices devices in the
+// order given by -device.sort-by, or only the one chosen by -device.single.
+// Devices whose handle can't be read are logged and left out, and complete
+// is false then. quiet leaves the logging to Collect.
+func (c *Collector) deviceHandles(numDevices uint, quiet bool) (devices []indexedDevice, complete bool) {
+    complete = true
+    for i := 0; i < int(numDevices); i++ {
+        dev, err := c.provider.DeviceHandleByIndex(uint(i))
+        if err != nil && c.recoverProvider(err) {
+            dev, err = c.provider.DeviceHandleByIndex(uint(i))
+        }
+        if err != nil {
+            if !quiet {
+                log.Printf("DeviceHandleByIndex(%d) error: %v", i, err)
+            }
+            complete = false
+            continue
+        }
+        if *deviceSingle != "" && !isSingleDevice(i, dev) {
+            continue
+        }
+        devices = append(devices, indexedDevice{i, dev})
+    }
+    if *deviceSingle != "" && len(devices) == 0 && !quiet {
+        log.Printf("No device matches -device.single=%s", *deviceSingle)
+    }
+
+    var key func(dev Device) string
+    switch *deviceSortBy {
+    case "uuid":
+        key = func(dev Device) string {
+            uuid, _ := dev.UUID()
+            return uuid
+        }
+    case "pci-bus-id":
+        key = func(dev Device) string {
+            uuid, err := dev.UUID()
+            if err != nil {
+                return ""
+            }
+            return c.staticInfo(dev, uuid).pciBusID
+        }
+    default:
+        return devices, complete
+    }
+
+    keys := make(map[int]string, len(devices))
+    for _, d := range devices {
+        keys[d.index] = key(d.dev)
+        if keys[d.index] == "" && !c.sortKeyWarned[d.index] && !quiet {
+            log.Printf("Device %d has no %s to sort by for -device.sort-by; it sorts before the others", d.index, *deviceSortBy)
+            if c.sortKeyWarned == nil {
+                c.sortKeyWarned = make(map[int]bool)
+            }
+            c.sortKeyWarned[d.index] = true
+        }
+    }
+    sort.SliceStable(devices, func(i, j int) bool {
+        return keys[devices[i].index] < keys[devices[j].index]
+    })
+    return devices, complete
+}
+
+// isSingleDevice reports whether the device at index is the one chosen by
+// -device.single, by index or UUID.
+func isSingleDevice(index int, dev Device) bool {
+    if *deviceSingle == strconv.Itoa(index) {
+        return true
+    }
+    uuid, err := dev.UUID()
+    return err == nil && strings.EqualFold(uuid, *deviceSingle)
+}
+
+// forgetDevices drops the state kept between scrapes for devices not in
+// seen, so a device plugged back in or swapped in starts afresh, static
+// attributes included. The counters kept across scrapes lose
+// the series of those devices, and collection_error those of minor numbers
+// not in seenMinors.
+func (c *Collector) forgetDevices(seen map[string]bool, seenMinors map[string]bool) {
+    for uuid, lv := range c.deviceLabels {
+        if seen[uuid] {
+            continue
+        }
+        c.forgetCounterSeries(lv)
+        delete(c.deviceLabels, uuid)
+    }
+    for minor, errorLabels := range c.errorLabels {
+        if seenMinors[minor] {
+            continue
+        }
+        for l := range errorLabels {
+            c.collectionErrors.DeleteLabelValues(minor, l.call, l.class)
+        }
+        delete(c.errorLabels, minor)
+    }
+    for uuid := range c.static {
+        if !seen[uuid] {
+            delete(c.static, uuid)
+        }
+    }
+    for uuid := range c.idleSince {
+        if !seen[uuid] {
+            delete(c.idleSince, uuid)
+        }
+    }
+    for uuid := range c.prevGrClock {
+        if !seen[uuid] {
+            delete(c.prevGrClock, uuid)
+        }
+    }
+    for uuid := range c.prevThrottle {
+        if !seen[uuid] {
+            delete(c.prevThrottle, uuid)
+        }
+    }
+    for uuid := range c.prevTemperature {
+        if !seen[uuid] {
+            delete(c.prevTemperature, uuid)
+        }
+    }
+    for uuid := range c.fanSpeedEMA {
+        if !seen[uuid] {
+            delete(c.fanSpeedEMA, uuid)
+        }
+    }
+    for uuid := range c.prevPCIeCounters {
+        if !seen[uuid] {
+            delete(c.prevPCIeCounters, uuid)
+        }
+    }
+}
+
+// setDeviceLabels records lv as the label values of the device with uuid.
+// If they changed, e.g. its role after the role map was reloaded, the
+// counters kept across scrapes drop the series under the previous ones,
+// which would otherwise be exported forever.
+func (c *Collector) setDeviceLabels(uuid string, lv []string) {
+    if prev, ok := c.deviceLabels[uuid]; ok && !sameLabelValues(prev, lv) {
+        c.forgetCounterSeries(prev)
+    }
+    c.deviceLabels[uuid] = lv
+}
+
+// forgetCounterSeries deletes the series of the counters kept across scrapes
+// with the device label values lv.
+func (c *Collector) forgetCounterSeries(lv []string) {
+    for _, r := range throttleReasons {
+        c.throttleReasonScrapes.DeleteLabelValues(append(lv, r.name)...)
+    }
+    c.thermalThrottleSeconds.DeleteLabelValues(lv...)
+    c.thermalThrottleEvents.DeleteLabelValues(lv...)
+}
+
+func sameLabelValues(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,168 @@
+package collector
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// countingProvider serves count numberedDevices; tests change count between
+// gathers to plug devices in and out.
+type countingProvider struct {
+    count *uint
+}
+
+func (p countingProvider) DeviceCount() (uint, error) {
+    return *p.count, nil
+}
+
+func (p countingProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    if index >= *p.count {
+        return nil, fmt.Errorf("no device with index %d", index)
+    }
+    return numberedDevice{index: index}, nil
+}
+
+// numberedDevice is a mockDevice with its own minor number and UUID. Its fan
+// speed can't be read, so it has collection_error series too.
+type numberedDevice struct {
+    mockDevice
+    index uint
+}
+
+func (d numberedDevice) MinorNumber() (uint, error) { return d.index, nil }
+func (d numberedDevice) UUID() (string, error)      { return fmt.Sprintf("GPU-%d", d.index), nil }
+func (d numberedDevice) FanSpeed() (uint, error)    { return 0, errors.New("NVML: Unknown Error") }
+
+// hasSeries reports whether mf has a metric with the label name set to value.
+func hasSeries(mf *dto.MetricFamily, name, value string) bool {
+    for _, m := range mf.GetMetric() {
+        for _, l := range m.GetLabel() {
+            if l.GetName() == name && l.GetValue() == value {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func TestForgetDevicesDeletesCounterSeries(t *testing.T) {
+    count := uint(2)
+    reg := prometheus.NewPedanticRegistry()
+    if err := reg.Register(NewCollector(countingProvider{&count})); err != nil {
+        t.Fatal(err)
+    }
+
+    perDevice := []struct{ metric, label, removed, kept string }{
+        {namespace + "_throttle_reason_scrapes_total", "uuid", "GPU-1", "GPU-0"},
+        {namespace + "_thermal_throttle_active_seconds", "uuid", "GPU-1", "GPU-0"},
+        {namespace + "_thermal_throttle_events_total", "uuid", "GPU-1", "GPU-0"},
+        {namespace + "_collection_error", "minor_number", "1", "0"},
+    }
+
+    mfs := gatherByName(t, reg)
+    for _, s := range perDevice {
+        if !hasSeries(mfs[s.metric], s.label, s.removed) || !hasSeries(mfs[s.metric], s.label, s.kept) {
+            t.Errorf("%s: want series for both devices before one is removed", s.metric)
+        }
+    }
+
+    count = 1
+    mfs = gatherByName(t, reg)
+    for _, s := range perDevice {
+        if hasSeries(mfs[s.metric], s.label, s.removed) {
+            t.Errorf("%s: series of the removed device %s=%q still exported", s.metric, s.label, s.removed)
+        }
+        if !hasSeries(mfs[s.metric], s.label, s.kept) {
+            t.Errorf("%s: series of the remaining device %s=%q gone", s.metric, s.label, s.kept)
+        }
+    }
+    changed := mfs[namespace+"_device_count_changed_total"]
+    if got := changed.GetMetric()[0].GetCounter().GetValue(); got != 1 {
+        t.Errorf("device_count_changed_total = %v, want 1", got)
+    }
+
+    count = 2
+    mfs = gatherByName(t, reg)
+    for _, s := range perDevice {
+        if !hasSeries(mfs[s.metric], s.label, s.removed) {
+            t.Errorf("%s: no series for the device plugged back in", s.metric)
+        }
+    }
+}
+
+func TestClocksStatusRespectsDeviceSingle(t *testing.T) {
+    defer func(single string) { *deviceSingle = single }(*deviceSingle)
+    *deviceSingle = "GPU-1"
+
+    count := uint(3)
+    status := NewCollector(countingProvider{&count}).clocksStatus()
+    if !strings.Contains(status, "(GPU-1)") || strings.Contains(status, "(GPU-0)") || strings.Contains(status, "(GPU-2)") {
+        t.Errorf("/clocks-status with -device.single=GPU-1:\n%s", status)
+    }
+}
+
+func TestForgetDevicesOnSwapAtSameCount(t *testing.T) {
+    devices := listProvider{numberedDevice{index: 0}, numberedDevice{index: 1}}
+    reg := prometheus.NewPedanticRegistry()
+    c := NewCollector(devices)
+    if err := reg.Register(c); err != nil {
+        t.Fatal(err)
+    }
+    gatherByName(t, reg)
+
+    devices[1] = numberedDevice{index: 2}
+    mfs := gatherByName(t, reg)
+    scrapes := mfs[namespace+"_throttle_reason_scrapes_total"]
+    if hasSeries(scrapes, "uuid", "GPU-1") || !hasSeries(scrapes, "uuid", "GPU-2") {
+        t.Errorf("throttle_reason_scrapes_total still has the swapped out GPU-1, or lacks GPU-2")
+    }
+    if _, ok := c.static["GPU-1"]; ok {
+        t.Errorf("static info of the swapped out GPU-1 kept")
+    }
+}
+
+func TestRoleChangeDeletesCounterSeries(t *testing.T) {
+    defer func(l []string, roles *roleMap) { labels, deviceRoles = l, roles }(labels, deviceRoles)
+    labels = append(labels[:len(labels):len(labels)], roleLabel)
+    deviceRoles = &roleMap{}
+
+    dir, err := ioutil.TempDir("", "roles")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    path := filepath.Join(dir, "roles")
+    loadRoles := func(role string) {
+        if err := ioutil.WriteFile(path, []byte("GPU-0 "+role+"\n"), 0644); err != nil {
+            t.Fatal(err)
+        }
+        // What reloadOnSIGHUP does.
+        if err := deviceRoles.load(path); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    count := uint(1)
+    reg := prometheus.NewPedanticRegistry()
+    if err := reg.Register(NewCollector(countingProvider{&count})); err != nil {
+        t.Fatal(err)
+    }
+    loadRoles("training")
+    gatherByName(t, reg)
+    loadRoles("inference")
+    mfs := gatherByName(t, reg)
+    for _, name := range []string{"_throttle_reason_scrapes_total", "_thermal_throttle_active_seconds", "_thermal_throttle_events_total"} {
+        mf := mfs[namespace+name]
+        if hasSeries(mf, roleLabel, "training") || !hasSeries(mf, roleLabel, "inference") {
+            t.Errorf("%s: want only series with the reloaded role", name)
+        }
+    }
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+    "strings"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// cudaDriverVersionReader is implemented by providers reporting the CUDA
+// driver API version the driver supports (nvmlSystemGetCudaDriverVersion),
+// e.g. 12020 for CUDA 12.2.
+type cudaDriverVersionReader interface {
+    CudaDriverVersion() (int, error)
+}
+
+// driverBranch returns the release branch of a driver version, e.g. R535 for
+// 535.104.05, or "" if version doesn't start with the branch number.
+func driverBranch(version string) string {
+    major := version
+    if i := strings.Index(version, "."); i >= 0 {
+        major = version[:i]
+    }
+    if major == "" || strings.Trim(major, "0123456789") != "" {
+        return ""
+    }
+    return "R" + major
+}
+
+// newDriverInfo returns the driver_info metric for the driver version read
+// at startup.
+func newDriverInfo(version string) prometheus.Collector {
+    info := prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Namespace: namespace,
+            Name:      "driver_info",
+            Help:      "Version and release branch of the NVIDIA driver as labels, value is always 1",
+        },
+        []string{"version", "driver_branch"},
+    )
+    info.WithLabelValues(version, driverBranch(version)).Set(1)
+    return info
+}
+
+// newCUDADriverVersion returns the cuda_driver_version metric for the
+// version read at startup.
+func newCUDADriverVersion(version int) prometheus.Gauge {
+    g := prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: namespace,
+            Name:      "cuda_driver_version",
+            Help:      "CUDA driver API version supported by the NVIDIA driver, as 1000*major + 10*minor, e.g. 12020 for 12.2",
+        },
+    )
+    g.Set(float64(version))
+    return g
+}
+
+// nvmlMatchesDriver reports whether the loaded NVML library belongs to the
+// running driver. NVML versions are the CUDA major version followed by the
+// driver version, e.g. 12.535.104.05 for driver 535.104.05. A library left
+// over from another driver, as happens with containers mounting the wrong
+// one, may lack calls the driver has or the other way round.
+func nvmlMatchesDriver(nvmlVersion, driverVersion string) bool {
+    return strings.HasSuffix(nvmlVersion, "."+driverVersion)
+}
+
+// newNVMLDriverCompatible returns the nvml_driver_compatible metric for the
+// versions read at startup.
+func newNVMLDriverCompatible(compatible bool) prometheus.Gauge {
+    g := prometheus.NewGauge(
+        prometheus.GaugeOpts{
+            Namespace: namespace,
+            Name:      "nvml_driver_compatible",
+            Help:      "1 if the loaded NVML library comes with the running driver, 0 if their versions differ and metrics may be missing",
+        },
+    )
+    g.Set(boolToFloat(compatible))
+    return g
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// envPrefix prefixes the environment variables that can set any flag.
+const envPrefix = "NVIDIA_EXPORTER_"
+
+// flagEnvName returns the environment variable for a flag: the flag name
+// upper-cased with dots and dashes turned into underscores, e.g.
+// NVIDIA_EXPORTER_WEB_LISTEN_ADDRESS for -web.listen-address.
+func flagEnvName(name string) string {
+    return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// setFlagsFromEnv sets every flag of fs that has its environment variable
+// set. It must run before fs.Parse so command line flags take precedence.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+    var err error
+    fs.VisitAll(func(f *flag.Flag) {
+        if err != nil {
+            return
+        }
+        env := flagEnvName(f.Name)
+        if v, ok := os.LookupEnv(env); ok {
+            if setErr := fs.Set(f.Name, v); setErr != nil {
+                err = fmt.Errorf("invalid value %q for %s: %v", v, env, setErr)
+            }
+        }
+    })
+    return err
+}
@@ -0,0 +1,52 @@
+package collector
+
+import "strings"
+
+// errorClass sorts a device call error into the classes exported by
+// nvidia_gpu_collection_error, going by NVML's error strings.
+func errorClass(err error) string {
+    msg := err.Error()
+    switch {
+    case isNotSupported(err):
+        return "unsupported"
+    case strings.Contains(msg, "Not Ready") || strings.Contains(msg, "Uninitialized"):
+        return "not_ready"
+    case strings.Contains(msg, "GPU is lost") || strings.Contains(msg, "Reset Required") ||
+        strings.Contains(msg, "Driver Not Loaded"):
+        return "gpu_lost"
+    case strings.Contains(msg, "Insufficient Permissions") || strings.Contains(msg, "No Permission"):
+        return "permission"
+    default:
+        return "unknown"
+    }
+}
+
+// callError logs a failed call on the device being collected, the same way
+// logCallError does, and counts it by error class. A nil err is ignored.
+func (c *Collector) callError(call string, err error) {
+    if err == nil {
+        return
+    }
+    logCallError(call, err)
+    c.countError(call, err)
+}
+
+// countError counts a failed call on the device being collected by error
+// class, without logging it. A nil err is ignored.
+func (c *Collector) countError(call string, err error) {
+    if err == nil {
+        return
+    }
+    l := errorLabel{call, errorClass(err)}
+    c.collectionErrors.WithLabelValues(c.minor, l.call, l.class).Inc()
+    if c.errorLabels[c.minor] == nil {
+        c.errorLabels[c.minor] = make(map[errorLabel]bool)
+    }
+    c.errorLabels[c.minor][l] = true
+}
+
+// errorLabel is the call and error class of a collection_error series, kept
+// by minor number so forgetDevices can delete them.
+type errorLabel struct {
+    call, class string
+}
@@ -0,0 +1,26 @@
+package collector_test
+
+import (
+    "log"
+    "net/http"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "nvidia_gpu_prometheus_exporter/collector"
+)
+
+// Serves the GPU metrics under a service's own mux and path.
+func ExampleRegisterCollector() {
+    // Flags not set keep their defaults.
+    if err := collector.Flags().Set("metrics.exclude", "nvidia_gpu_process_*"); err != nil {
+        log.Fatal(err)
+    }
+    reg := prometheus.NewRegistry()
+    if err := collector.RegisterCollector(reg); err != nil {
+        log.Fatal(err)
+    }
+    defer collector.Shutdown()
+
+    mux := http.NewServeMux()
+    mux.Handle("/gpu/metrics", collector.Handler())
+    log.Fatal(http.ListenAndServe(":8080", mux))
+}
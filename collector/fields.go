@@ -0,0 +1,81 @@
+package collector
+
+import (
+    "fmt"
+    "log"
+    "strconv"
+    "strings"
+)
+
+// fieldValueReader is implemented by devices reading arbitrary NVML field
+// values (nvmlDeviceGetFieldValues) by their NVML_FI_* ID.
+type fieldValueReader interface {
+    FieldValue(fieldID uint) (float64, error)
+}
+
+// extraFields are the -nvml.extra-fields IDs left after probeExtraFields.
+var extraFields []uint
+
+// parseFieldIDs splits a comma separated list of NVML field IDs.
+func parseFieldIDs(list string) ([]uint, error) {
+    var ids []uint
+    for _, s := range strings.Split(list, ",") {
+        s = strings.TrimSpace(s)
+        if s == "" {
+            continue
+        }
+        id, err := strconv.ParseUint(s, 10, 32)
+        if err != nil || id == 0 {
+            return nil, fmt.Errorf("invalid field ID %q", s)
+        }
+        ids = append(ids, uint(id))
+    }
+    return ids, nil
+}
+
+// probeExtraFields reads each field from the first device and drops the
+// ones it can't read, so a wrong ID is reported once at startup rather than
+// on every scrape. Without devices to probe the fields are kept as is.
+func probeExtraFields(p Provider, ids []uint) []uint {
+    numDevices, err := p.DeviceCount()
+    if err != nil || numDevices == 0 {
+        return ids
+    }
+    dev, err := p.DeviceHandleByIndex(0)
+    if err != nil {
+        return ids
+    }
+    r, ok := dev.(fieldValueReader)
+    if !ok {
+        log.Printf("The %s backend can't read NVML field values, ignoring -nvml.extra-fields", *backend)
+        return nil
+    }
+
+    var usable []uint
+    for _, id := range ids {
+        if _, err := r.FieldValue(id); err != nil {
+            log.Printf("Skipping NVML field %d: %v", id, err)
+            continue
+        }
+        usable = append(usable, id)
+    }
+    return usable
+}
+
+func (c *Collector) collectFields(dev Device, lv []string) error {
+    r, ok := dev.(fieldValueReader)
+    if !ok {
+        return nil
+    }
+
+    var errs firstError
+    for _, id := range extraFields {
+        v, err := r.FieldValue(id)
+        if err == nil {
+            c.set(c.field, append(lv, strconv.FormatUint(uint64(id), 10)), v)
+        }
+        c.countError("FieldValue", err)
+        errs.record(err)
+    }
+    return errs.err
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+    "fmt"
+    "path"
+    "strings"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// metricFilter selects the metric families served, by glob patterns matched
+// against the full metric name. With no include patterns every metric is
+// included; exclude patterns win over include patterns.
+type metricFilter struct {
+    include []string
+    exclude []string
+}
+
+// metricsFilter is the filter of -metrics.include and -metrics.exclude, set
+// by applyFlags.
+var metricsFilter *metricFilter
+
+// parseGlobs splits a comma separated list of glob patterns and checks that
+// each one is well formed.
+func parseGlobs(list string) ([]string, error) {
+    var patterns []string
+    for _, p := range strings.Split(list, ",") {
+        p = strings.TrimSpace(p)
+        if p == "" {
+            continue
+        }
+        if _, err := path.Match(p, ""); err != nil {
+            return nil, fmt.Errorf("invalid pattern %q: %v", p, err)
+        }
+        patterns = append(patterns, p)
+    }
+    return patterns, nil
+}
+
+func newMetricFilter(include, exclude string) (*metricFilter, error) {
+    inc, err := parseGlobs(include)
+    if err != nil {
+        return nil, fmt.Errorf("-metrics.include: %v", err)
+    }
+    exc, err := parseGlobs(exclude)
+    if err != nil {
+        return nil, fmt.Errorf("-metrics.exclude: %v", err)
+    }
+    return &metricFilter{include: inc, exclude: exc}, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+    for _, p := range patterns {
+        // The patterns were validated, so Match can't fail.
+        if ok, _ := path.Match(p, name); ok {
+            return true
+        }
+    }
+    return false
+}
+
+func (f *metricFilter) allows(name string) bool {
+    if matchAny(f.exclude, name) {
+        return false
+    }
+    return len(f.include) == 0 || matchAny(f.include, name)
+}
+
+// gatherer wraps g so it only returns the metric families f allows.
+func (f *metricFilter) gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+    return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+        mfs, err := g.Gather()
+        filtered := mfs[:0]
+        for _, mf := range mfs {
+            if f.allows(mf.GetName()) {
+                filtered = append(filtered, mf)
+            }
+        }
+        return filtered, err
+    })
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// healthSignals are the inputs of health_score, in the order they are
+// documented. Each gives a penalty between 0 (healthy) and 1.
+var healthSignals = []string{"ecc", "remapping", "throttling", "thermal", "pcie"}
+
+// healthWeights are the parsed -health.weights.
+var healthWeights = map[string]float64{"ecc": 4, "remapping": 4, "throttling": 1, "thermal": 2, "pcie": 1}
+
+// healthThermalMargin is the thermal headroom in celsius below which the
+// thermal penalty starts to grow, reaching 1 at the slowdown threshold.
+const healthThermalMargin = 10
+
+// parseHealthWeights parses a comma separated list of signal=weight pairs
+// into healthWeights. Signals not in the list keep their default weight.
+func parseHealthWeights(list string) error {
+    for _, pair := range strings.Split(list, ",") {
+        pair = strings.TrimSpace(pair)
+        if pair == "" {
+            continue
+        }
+        i := strings.Index(pair, "=")
+        if i < 0 {
+            return fmt.Errorf("invalid weight %q, must be signal=weight", pair)
+        }
+        signal := pair[:i]
+        if _, ok := healthWeights[signal]; !ok {
+            return fmt.Errorf("unknown signal %q, must be one of %s", signal, strings.Join(healthSignals, ", "))
+        }
+        w, err := strconv.ParseFloat(pair[i+1:], 64)
+        if err != nil || w < 0 {
+            return fmt.Errorf("invalid weight %q for %s", pair[i+1:], signal)
+        }
+        healthWeights[signal] = w
+    }
+    return nil
+}
+
+// healthScoreHelp documents the score and the weights in use.
+func healthScoreHelp() string {
+    weights := make([]string, 0, len(healthSignals))
+    for _, signal := range healthSignals {
+        weights = append(weights, fmt.Sprintf("%s=%g", signal, healthWeights[signal]))
+    }
+    return "Health of the GPU device from 0 to 100: 100 minus the weighted mean penalty of the signals available for it, " +
+        "ecc (volatile uncorrected ECC errors), remapping (a failed row remapping, or half for one waiting for a reset), throttling (power or thermal throttle reasons), thermal (headroom under " +
+        strconv.Itoa(healthThermalMargin) + "C) and pcie (degraded link). Weights: " + strings.Join(weights, ", ")
+}
+
+// healthPenalties returns the penalty of each signal available in r.
+func healthPenalties(r deviceReadings) map[string]float64 {
+    penalties := make(map[string]float64)
+    if r.haveECC {
+        penalties["ecc"] = boolToFloat(r.uncorrectedECCErrors > 0)
+    }
+    if r.haveRemappedRows {
+        switch {
+        case r.remappingFailed:
+            penalties["remapping"] = 1
+        case r.remappingPending:
+            penalties["remapping"] = 0.5
+        default:
+            penalties["remapping"] = 0
+        }
+    }
+    if r.haveThrottleReasons {
+        penalties["throttling"] = boolToFloat(r.throttleReasons&limiterThrottleReasons != 0)
+    }
+    if r.slowdownTemp > 0 {
+        headroom := float64(r.slowdownTemp) - float64(r.temperature)
+        p := (healthThermalMargin - headroom) / healthThermalMargin
+        if p < 0 {
+            p = 0
+        } else if p > 1 {
+            p = 1
+        }
+        penalties["thermal"] = p
+    }
+    if r.havePCIeDegraded {
+        penalties["pcie"] = boolToFloat(r.pcieDegraded)
+    }
+    return penalties
+}
+
+// healthScore returns the score for r, or false if none of its weighted
+// signals are available.
+func healthScore(r deviceReadings) (float64, bool) {
+    var sum, total float64
+    for signal, p := range healthPenalties(r) {
+        w := healthWeights[signal]
+        sum += w * p
+        total += w
+    }
+    if total == 0 {
+        return 0, false
+    }
+    return 100 * (1 - sum/total), true
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+    "fmt"
+    "log"
+    "strings"
+)
+
+// instanceLabel is the label naming the -nvml.instances entry a device was
+// read through.
+const instanceLabel = "nvml_instance"
+
+// instanceSpec is one -nvml.instances entry.
+type instanceSpec struct {
+    name string
+    path string
+}
+
+// parseInstances parses -nvml.instances, a comma separated list of
+// name=path entries.
+func parseInstances(list string) ([]instanceSpec, error) {
+    var specs []instanceSpec
+    seen := make(map[string]bool)
+    for _, entry := range strings.Split(list, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        i := strings.Index(entry, "=")
+        if i <= 0 || i == len(entry)-1 {
+            return nil, fmt.Errorf("invalid instance %q, must be name=path", entry)
+        }
+        name, path := entry[:i], entry[i+1:]
+        if seen[name] {
+            return nil, fmt.Errorf("duplicate instance name %q", name)
+        }
+        seen[name] = true
+        specs = append(specs, instanceSpec{name, path})
+    }
+    return specs, nil
+}
+
+// instanceNamer is implemented by providers aggregating several instances,
+// naming the one the device at index belongs to.
+type instanceNamer interface {
+    Instance(index uint) string
+}
+
+type providerInstance struct {
+    name     string
+    provider Provider
+}
+
+// multiProvider serves the devices of several providers one after the
+// other. An instance failing to count its devices is logged and left out of
+// the scrape rather than failing the others.
+type multiProvider struct {
+    instances []providerInstance
+    counts    []uint
+}
+
+func newMultiProvider(instances []providerInstance) *multiProvider {
+    return &multiProvider{instances: instances, counts: make([]uint, len(instances))}
+}
+
+func (p *multiProvider) DeviceCount() (uint, error) {
+    var total uint
+    var firstErr error
+    failed := 0
+    for i, inst := range p.instances {
+        n, err := inst.provider.DeviceCount()
+        if err != nil {
+            log.Printf("Instance %s: DeviceCount() error: %v", inst.name, err)
+            if firstErr == nil {
+                firstErr = err
+            }
+            failed++
+            n = 0
+        }
+        p.counts[i] = n
+        total += n
+    }
+    if failed == len(p.instances) {
+        return 0, firstErr
+    }
+    return total, nil
+}
+
+// locate returns the instance serving the device at index and its index
+// within that instance.
+func (p *multiProvider) locate(index uint) (int, uint, bool) {
+    for i, n := range p.counts {
+        if index < n {
+            return i, index, true
+        }
+        index -= n
+    }
+    return 0, 0, false
+}
+
+func (p *multiProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    i, local, ok := p.locate(index)
+    if !ok {
+        return nil, fmt.Errorf("no device with index %d", index)
+    }
+    return p.instances[i].provider.DeviceHandleByIndex(local)
+}
+
+func (p *multiProvider) Instance(index uint) string {
+    i, _, ok := p.locate(index)
+    if !ok {
+        return ""
+    }
+    return p.instances[i].name
+}
+
+// Reinitialize re-initializes every instance that supports it, each on its
+// own.
+func (p *multiProvider) Reinitialize() error {
+    var firstErr error
+    for _, inst := range p.instances {
+        r, ok := inst.provider.(reinitializer)
+        if !ok {
+            continue
+        }
+        if err := r.Reinitialize(); err != nil {
+            log.Printf("Instance %s: couldn't re-initialize: %v", inst.name, err)
+            if firstErr == nil {
+                firstErr = err
+            }
+        }
+    }
+    return firstErr
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+    "fmt"
+    "strings"
+)
+
+// dropLabels removes the per-device labels named in the comma separated list
+// from labels. uuid identifies the device and can't be dropped.
+func dropLabels(list string) error {
+    drop := make(map[string]bool)
+    for _, name := range strings.Split(list, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+        if name == "uuid" {
+            return fmt.Errorf("the uuid label identifies the device and can't be dropped")
+        }
+        known := false
+        for _, l := range labels {
+            known = known || l == name
+        }
+        if !known {
+            return fmt.Errorf("unknown label %q, must be one of %s", name, strings.Join(labels, ", "))
+        }
+        drop[name] = true
+    }
+
+    kept := labels[:0:0]
+    for _, l := range labels {
+        if !drop[l] {
+            kept = append(kept, l)
+        }
+    }
+    labels = kept
+    return nil
+}
+
+// deviceLabelValues returns the values of the per-device labels that weren't
+// dropped, in the order of labels.
+func deviceLabelValues(minor, uuid, name, instance string) []string {
+    lv := make([]string, 0, len(labels))
+    for _, l := range labels {
+        switch l {
+        case "minor_number":
+            lv = append(lv, minor)
+        case "uuid":
+            lv = append(lv, labelCase(uuid))
+        case "name":
+            lv = append(lv, labelCase(name))
+        case instanceLabel:
+            lv = append(lv, instance)
+        case roleLabel:
+            lv = append(lv, deviceRoles.role(uuid))
+        }
+    }
+    return lv
+}
+
+// labelCase lowercases a uuid or name label value with -labels.lowercase, so
+// a driver update changing their case doesn't start new series.
+func labelCase(v string) string {
+    if *labelsLowercase {
+        return strings.ToLower(v)
+    }
+    return v
+}
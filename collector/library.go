@@ -0,0 +1,62 @@
+package collector
+
+import (
+    "flag"
+    "net/http"
+
+    "github.com/cfsmp3/gonvml"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Flags returns the exporter's flags. They are kept out of flag.CommandLine;
+// services embedding the exporter set or parse them before calling
+// RegisterCollector, and flags left alone keep their defaults.
+func Flags() *flag.FlagSet {
+    return flags
+}
+
+// registered is the registry RegisterCollector registered the collector
+// with, served by Handler.
+var registered *prometheus.Registry
+
+// RegisterCollector registers the collector for the devices of -backend
+// with reg, along with the metrics about the backend such as the driver
+// version. Services embedding the exporter call it once, mount Handler under
+// their own mux and call Shutdown when done. To read the devices from
+// elsewhere, register NewCollector with a Provider instead.
+func RegisterCollector(reg *prometheus.Registry) error {
+    if _, err := registerCollector(reg); err != nil {
+        return err
+    }
+    registered = reg
+    return nil
+}
+
+// Shutdown releases NVML if RegisterCollector initialized it.
+func Shutdown() {
+    if nvmlInitialized {
+        gonvml.Shutdown()
+        nvmlInitialized = false
+    }
+}
+
+// Handler returns the metrics handler for the registry passed to
+// RegisterCollector, for mounting at any path of any mux. Like the
+// exporter's own, it serves only the metrics -metrics.include and
+// -metrics.exclude let through, and is instrumented with the promhttp_*
+// metrics.
+func Handler() http.Handler {
+    if registered == nil {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            http.Error(w, "RegisterCollector wasn't called", http.StatusInternalServerError)
+        })
+    }
+    return metricsHandler(registered, metricsFilter.gatherer(registered))
+}
+
+// metricsHandler returns the handler serving what g gathers, instrumented
+// with the promhttp_* metrics registered with reg.
+func metricsHandler(reg prometheus.Registerer, g prometheus.Gatherer) http.Handler {
+    return promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+}
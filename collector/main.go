@@ -0,0 +1,2114 @@
+// Copied from https://github.com/mindprince/nvidia_gpu_prometheus_exporter
+// https://github.com/mindprince/nvidia_gpu_prometheus_exporter/blob/master/LICENSE
+
+package collector
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "net"
+    "net/http"
+    "net/http/pprof"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/cfsmp3/gonvml"
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+    namespace = "nvidia_gpu"
+)
+
+// flags are the exporter's flags. They have their own set rather than
+// flag.CommandLine, so importing the package doesn't add them to the flags
+// of the program embedding it.
+var flags = flag.NewFlagSet("nvidia_gpu_prometheus_exporter", flag.ExitOnError)
+
+var (
+    addr = flags.String("web.listen-address", ":9445", "Address to listen on for web interface and telemetry. Empty disables the HTTP server, e.g. when only -output.file is wanted")
+    adminAddr = flags.String("web.admin-listen-address", "", "If set, serve /healthz, the debug endpoints and pprof on this address instead of -web.listen-address")
+    listenInterface = flags.String("web.listen-interface", "", "Network interface to listen on, e.g. eth1, for hosts whose address isn't stable. -web.listen-address then only gives the port")
+    outputFile = flags.String("output.file", "", "If set, periodically write the metrics in the text format to this file, e.g. for node_exporter's textfile collector")
+    outputInterval = flags.Duration("output.interval", 15*time.Second, "How often to write -output.file")
+    otlpEndpoint = flags.String("otlp.endpoint", "", "If set, periodically push the metrics to this OTLP/HTTP receiver, e.g. http://localhost:4318/v1/metrics")
+    otlpInterval = flags.Duration("otlp.interval", 15*time.Second, "How often to push to -otlp.endpoint")
+    backend = flags.String("backend", "nvml", "Where to read device metrics from: nvml or nvidia-smi")
+    nvmlInstances = flags.String("nvml.instances", "", "Comma separated name=path list of nvidia-smi commands to collect from, e.g. wrappers entering the mount namespaces of containers that each see other GPUs. Adds an nvml_instance label. Only for -backend=nvidia-smi: NVML can only be loaded once per process, so the nvml backend refuses to start with it")
+    nvidiaSmiPath = flags.String("nvidia-smi.path", "nvidia-smi", "Path to the nvidia-smi binary used by the nvidia-smi backend")
+    enableFanSpeed = flags.Bool("enable-fanspeed", true, "Enable fanspeed metric")
+    fanSmoothing = flags.Float64("fan.smoothing", 0, "If set, export an exponential moving average of the fan speed instead of the raw reading, with this weight (0-1] for each new reading. Lower is smoother")
+    enableFanFailureDetection = flags.Bool("enable-fan-failure-detection", false, "Export nvidia_gpu_fan_failure_suspected, a heuristic flagging a fan at ~0% on a GPU above its slowdown temperature. Needs -enable-fanspeed")
+    enablePowerLimits = flags.Bool("enable-powerlimits", true, "Enable power limit metrics")
+    expectedPowerLimit = flags.Float64("power.expected-limit-watts", 0, "If set, export nvidia_gpu_power_limit_drift, 1 for devices whose power management limit differs from this many watts, e.g. after a driver reload reverted a centrally managed limit")
+    powerUnit = flags.String("power.unit", "watts", "Unit of the power metrics: watts or milliwatts. Milliwatts keep the full NVML precision")
+    energyUnit = flags.String("energy.unit", "joules", "Unit of the energy consumption metric: joules, wh or kwh")
+    enableAveragePowerUsage = flags.Bool("enable-averagepowerusage", true, "Enable average power usage metric")
+    unifiedClocks = flags.Bool("metrics.unified-clocks", false, "Export all clock speeds as nvidia_gpu_clock_mhz with type and kind labels instead of one metric per clock")
+    utilizationSampleInterval = flags.Duration("utilization.sample-interval", 0, "If set, sample GPU utilization in the background at this interval and export its moving average as nvidia_gpu_utilization_ema_ratio")
+    labelsDrop = flags.String("labels.drop", "", "Comma separated per-device labels to leave out of all metrics, e.g. name. uuid can't be dropped")
+    labelsRoleMap = flags.String("labels.role-map", "", "File mapping device UUIDs to a role label, one \"<uuid> <role>\" pair per line. Devices not in it get role=\"unassigned\". Reloaded on SIGHUP")
+    labelsLowercase = flags.Bool("labels.lowercase", false, "Lowercase the uuid and name label values, so driver updates changing their case don't start new series")
+    metricsInclude = flags.String("metrics.include", "", "Comma separated glob patterns of metric names to expose, e.g. nvidia_gpu_memory_*. Empty exposes everything")
+    metricsExclude = flags.String("metrics.exclude", "", "Comma separated glob patterns of metric names not to expose. Takes precedence over -metrics.include")
+    labelVbios = flags.Bool("label.vbios", false, "Expose the VBIOS version of each device as a label on nvidia_gpu_vbios_info")
+    enableProcessMetrics = flags.Bool("enable-process-metrics", false, "Enable per-process GPU memory metrics")
+    processNameAllowlist = flags.String("process.name-allowlist", "", "Comma separated glob patterns of process names that get their own process metric series; other processes are summed into pid=\"other\" unless they pass -process.min-memory-bytes")
+    processMinMemory = flags.Uint64("process.min-memory-bytes", 0, "GPU memory use from which a process gets its own process metric series; smaller ones are summed into pid=\"other\" unless allowlisted")
+    resolveContainers = flags.Bool("process.resolve-containers", false, "Add the container ID of each GPU process, read from /proc/<pid>/cgroup, as the container_id label of the process metrics")
+    enableVirtualizationMetrics = flags.Bool("enable-virtualization-metrics", false, "Enable the virtualization mode metrics")
+    enableInforomMetrics = flags.Bool("enable-inforom-metrics", false, "Enable the inforom validity and version metrics")
+    enableECCMetrics = flags.Bool("enable-ecc-metrics", false, "Enable ECC error counter metrics")
+    eccIncludeAggregate = flags.Bool("ecc.include-aggregate", true, "Also export the lifetime (aggregate) ECC counters besides the volatile ones. Both are read in the same call, so turning this off only drops the series")
+    enableMemoryFreeMetrics = flags.Bool("enable-memory-free-metrics", false, "Enable the free memory and memory fragmentation heuristic metrics")
+    pcieMode = flags.String("pcie.mode", "instant", "How to read the PCIe throughput: instant (NVML's 20ms sample) or counted (average since the previous scrape from the byte counters, where the driver has them, NVML 12 and later; otherwise counted falls back to instant)")
+    enableAERMetrics = flags.Bool("enable-aer-metrics", false, "Enable PCIe Advanced Error Reporting counters read from sysfs")
+    deviceSortBy = flags.String("device.sort-by", "index", "Order devices are collected in: index, uuid or pci-bus-id")
+    healthWeightsFlag = flags.String("health.weights", "", "Comma separated signal=weight pairs overriding the weights of nvidia_gpu_health_score, e.g. ecc=4,remapping=4,throttling=1,thermal=2,pcie=1")
+    deviceSingle = flags.String("device.single", "", "Only collect the device with this index or UUID, e.g. for one exporter sidecar per GPU. num_devices still counts all devices")
+    markRemovedDevices = flags.Bool("collector.mark-removed", false, "Export NaN for the series of a device that disappeared since the previous scrape, once, before dropping them")
+    enablePprof = flags.Bool("enable-pprof", false, "Serve the Go profiling endpoints under /debug/pprof/")
+    backoffOnLoad = flags.Duration("collector.backoff-on-load", 0, "If set, while a GPU's utilization is at least -collector.backoff-threshold, sweep the devices at most this often and answer the scrapes in between from the previous sweep, to keep NVML calls off busy GPUs")
+    backoffThreshold = flags.Float64("collector.backoff-threshold", 90, "GPU utilization percentage from which -collector.backoff-on-load applies")
+    activeOnly = flags.Bool("collector.active-only", false, "Only collect utilization, power and temperature for devices idle (0% utilization, no processes) for -collector.idle-grace")
+    idleGrace = flags.Duration("collector.idle-grace", 5*time.Minute, "How long a device has to be idle before -collector.active-only skips its other metrics")
+    callRetries = flags.Int("collector.call-retries", 0, "How many times to retry an NVML call failing with a transient (Not Ready, Timeout) error, 50ms apart")
+    zeroOnError = flags.Bool("collector.zero-on-error", false, "Export 0 instead of dropping a series when its reading fails. This hides failures from alerts on missing series")
+    validate = flags.Bool("validate-metrics", false, "Check that every described metric is collected for a mock device supporting every reading, then exit")
+    mode = flags.String("mode", "full", "What to collect: full, or inventory for only the static device information (VBIOS, board, driver model, MIG mode, supported throttle reasons) for inventory systems")
+    nvmlExtraFields = flags.String("nvml.extra-fields", "", "Comma separated NVML field IDs (NVML_FI_*) to export as nvidia_gpu_field{field_id}. Fields the first device can't read are skipped")
+    printMetrics = flags.Bool("print-metrics", false, "Print the name, type, help, labels and needed flags of every metric, then exit")
+    enableGoMetrics = flags.Bool("enable-go-metrics", true, "Expose the exporter's own Go runtime (go_*) and process (process_*) metrics")
+    enableDebugEndpoint = flags.Bool("enable-debug-endpoint", false, "Serve a JSON dump of the raw NVML call results under /debug/nvml, the decoded clock throttle reasons under /clocks-status and POST /reset-stats. Don't expose them publicly")
+    enableClockLockDetection = flags.Bool("enable-clock-lock-detection", false, "Enable nvidia_gpu_clocks_locked_by_user, flagging applications clocks left different from their defaults")
+    enableClockPolicyMetrics = flags.Bool("enable-clock-policy-metrics", false, "Enable auto-boost clock policy metrics")
+
+
+    labels = []string{"minor_number", "uuid", "name"}
+
+    averageDuration = flags.Duration("averaging-window", 15*time.Second, "Window the average power usage and GPU utilization are computed over. Should be at least the scrape interval")
+    averageDurations = flags.String("averaging-windows", "", "Comma separated windows, e.g. 1s,5s,15s, to export the average power usage and GPU utilization for, each with a window label. Overrides -averaging-window; at most 4. Without it the average metrics have no window label")
+)
+
+/* 
+                                                                                
+*/
+
+// autoBoostReader is implemented by devices exposing
+// nvmlDeviceGetAutoBoostedClocksEnabled. Most datacenter GPUs don't support it.
+type autoBoostReader interface {
+    AutoBoostedClocksEnabled() (enabled bool, defaultEnabled bool, err error)
+}
+
+// powerMetricName returns the name of a power metric in -power.unit.
+func powerMetricName(name string) string {
+    return name + "_" + *powerUnit
+}
+
+// powerValue converts an NVML milliwatt reading to -power.unit.
+func powerValue(milliwatts uint) float64 {
+    if *powerUnit == "milliwatts" {
+        return float64(milliwatts)
+    }
+    return float64(milliwatts / 1000)
+}
+
+// energyUnitInfo is a unit of the energy consumption metric.
+type energyUnitInfo struct {
+    suffix      string
+    millijoules float64
+}
+
+// energyUnits are the -energy.unit values.
+var energyUnits = map[string]energyUnitInfo{
+    "joules": {"joules", 1e3},
+    "wh":     {"watt_hours", 3.6e6},
+    "kwh":    {"kilowatt_hours", 3.6e9},
+}
+
+// energyMetricName returns the name of an energy metric in -energy.unit.
+func energyMetricName(name string) string {
+    return name + "_" + energyUnits[*energyUnit].suffix
+}
+
+// energyValue converts an NVML millijoule reading to -energy.unit.
+func energyValue(millijoules uint64) float64 {
+    return float64(millijoules) / energyUnits[*energyUnit].millijoules
+}
+
+// logCallError logs a failed device call. Readings the backend doesn't
+// provide at all aren't worth a log line on every scrape.
+func logCallError(call string, err error) {
+    if err == errNotSupported {
+        return
+    }
+    log.Printf("%s() error: %v", call, err)
+}
+
+// memoryTemperatureReader is implemented by devices reporting the HBM
+// temperature (NVML_FI_DEV_MEMORY_TEMP). Only HBM cards have the sensor.
+type memoryTemperatureReader interface {
+    MemoryTemperature() (uint, error)
+}
+
+// jpegUtilizationReader and ofaUtilizationReader are implemented by devices
+// exposing the utilization of the NVJPG and optical flow engines
+// (nvmlDeviceGetJpgUtilization, nvmlDeviceGetOfaUtilization). Only newer GPUs
+// have these engines.
+type jpegUtilizationReader interface {
+    JpgUtilization() (utilization uint, samplingPeriod uint, err error)
+}
+
+type ofaUtilizationReader interface {
+    OfaUtilization() (utilization uint, samplingPeriod uint, err error)
+}
+
+// eccErrorReader is implemented by devices exposing NVML's ECC error counts
+// (nvmlDeviceGetTotalEccErrors), all four counts in one call like gonvml's
+// accessor. Volatile counts reset when the driver is reloaded, aggregate
+// ones persist for the lifetime of the card.
+type eccErrorReader interface {
+    TotalEccErrors() (correctedVolatile, correctedAggregate, uncorrectedVolatile, uncorrectedAggregate uint64, err error)
+}
+
+// remappedRowsReader is implemented by devices reporting their row remapping
+// state (nvmlDeviceGetRemappedRows), Ampere and newer: the rows remapped for
+// corrected and uncorrected errors, whether a remapping waits for a GPU reset
+// and whether one failed.
+type remappedRowsReader interface {
+    RemappedRows() (corrected, uncorrected uint, pending, failed bool, err error)
+}
+
+// sramECCThresholdReader is implemented by devices reporting whether the
+// SRAM uncorrectable ECC error threshold was exceeded
+// (nvmlDeviceGetSramEccErrorStatus). Hopper and newer only.
+type sramECCThresholdReader interface {
+    SramEccErrorThresholdExceeded() (bool, error)
+}
+
+// driverModelReader is implemented by devices exposing the Windows driver
+// model (nvmlDeviceGetDriverModel), in NVML's encoding: NVML_DRIVER_WDDM (0)
+// or NVML_DRIVER_WDM (1, i.e. TCC). Returns not supported on Linux.
+type driverModelReader interface {
+    DriverModel() (current uint, pending uint, err error)
+}
+
+// migModeReader is implemented by devices reporting their MIG mode
+// (nvmlDeviceGetMigMode). A change only takes effect after a GPU reset, so
+// current and pending can differ. Cards without MIG return not supported.
+type migModeReader interface {
+    MigMode() (current uint, pending uint, err error)
+}
+
+// nvmlMigEnable is NVML_DEVICE_MIG_ENABLE.
+const nvmlMigEnable = 1
+
+// driverModelValue maps NVML's driver model to the exported value, TCC=0 and
+// WDDM=1.
+func driverModelValue(model uint) float64 {
+    if model == 0 {
+        return 1
+    }
+    return 0
+}
+
+// withLabels returns the per-device labels followed by extra.
+func withLabels(extra ...string) []string {
+    return append(append([]string{}, labels...), extra...)
+}
+
+func boolToFloat(b bool) float64 {
+    if b {
+        return 1
+    }
+    return 0
+}
+
+type Collector struct {
+    sync.Mutex
+    provider                        Provider
+    static                          map[string]*staticDeviceInfo
+    idleSince                       map[string]time.Time
+    prevGrClock                     map[string]clockSample
+    prevThrottle                    map[string]throttleSample
+    prevTemperature                 map[string]temperatureSample
+    fanSpeedEMA                     map[string]float64
+    prevPCIeCounters                map[string]pcieCounterSample
+    deviceLabels                    map[string][]string
+    errorLabels                     map[string]map[errorLabel]bool
+    collected                       int
+    minor                           string
+    uuid                            string
+    readings                        deviceReadings
+    utilizationEMA                  map[string]float64
+    series                          []seriesRef
+    numDevices                      prometheus.Gauge
+    duplicateUUID                   prometheus.Gauge
+    nvmlReinits                     prometheus.Counter
+    deviceCountChanged              prometheus.Counter
+    prevDeviceCount                 uint
+    haveDeviceCount                 bool
+    staleRecoveries                 prometheus.Counter
+    prevCollected                   int
+    scrapes                         prometheus.Counter
+    cachedScrapes                   prometheus.Counter
+    lastSweep                       time.Time
+    watch                           sweepWatch
+    sweptMetrics                    []prometheus.Metric
+    resetAt                         time.Time
+    maxUtilization                  uint
+    lastScrape                      time.Time
+    averagingWindowTooShort         prometheus.Gauge
+    warmupComplete                  prometheus.Gauge
+    lastSuccess                     prometheus.Gauge
+    enabledCollectors               prometheus.Metric
+    averagingWarned                 bool
+    sortKeyWarned                   map[int]bool
+    usedMemory                      *prometheus.GaugeVec
+    totalMemory                     *prometheus.GaugeVec
+    freeMemory                      *prometheus.GaugeVec
+    memoryFragmentation             *prometheus.GaugeVec
+    usedBar1Memory                  *prometheus.GaugeVec
+    totalBar1Memory                 *prometheus.GaugeVec
+    powerUsage                      *prometheus.GaugeVec
+    avgPowerUsage                   *prometheus.GaugeVec
+    energyConsumption               *prometheus.GaugeVec
+    temperature                     *prometheus.GaugeVec
+    temperatureThresholdShutDown    *prometheus.GaugeVec
+    temperatureThresholdSlowDown    *prometheus.GaugeVec
+    thermalHeadroom                 *prometheus.GaugeVec
+    temperatureChange               *prometheus.GaugeVec
+    temperatureThreshold            *prometheus.GaugeVec
+    throttlingReason                *prometheus.GaugeVec
+    throttleReasonSupported         *prometheus.GaugeVec
+    throttleReasonScrapes           *prometheus.CounterVec
+    thermalThrottleSeconds          *prometheus.CounterVec
+    thermalThrottleEvents           *prometheus.CounterVec
+    fanSpeed                        *prometheus.GaugeVec
+    fanFailureSuspected             *prometheus.GaugeVec
+    encUsage                        *prometheus.GaugeVec
+    decUsage                        *prometheus.GaugeVec
+    jpegUsage                       *prometheus.GaugeVec
+    ofaUsage                        *prometheus.GaugeVec
+    GPUUtilizationRate              *prometheus.GaugeVec
+    avgGPUUtilization               *prometheus.GaugeVec
+    utilizationEMARatio             *prometheus.GaugeVec
+    memoryUtilizationRate           *prometheus.GaugeVec
+    computeMode                     *prometheus.GaugeVec
+    performanceState                *prometheus.GaugeVec
+    driverModelCurrent              *prometheus.GaugeVec
+    driverModelPending              *prometheus.GaugeVec
+    migModeCurrent                  *prometheus.GaugeVec
+    migModePending                  *prometheus.GaugeVec
+    grClockCurrent                  *prometheus.GaugeVec
+    grClockMax                      *prometheus.GaugeVec
+    grClockMin                      *prometheus.GaugeVec
+    grClockCustomerMaxBoost         *prometheus.GaugeVec
+    grClockRequested                *prometheus.GaugeVec
+    SMClockCurrent                  *prometheus.GaugeVec
+    SMClockMax                      *prometheus.GaugeVec
+    memClockCurrent                 *prometheus.GaugeVec
+    memClockMax                     *prometheus.GaugeVec
+    memClockMin                     *prometheus.GaugeVec
+    videoClockCurrent               *prometheus.GaugeVec
+    videoClockMax                   *prometheus.GaugeVec
+    grClockThrottled                *prometheus.GaugeVec
+    memClockThrottled               *prometheus.GaugeVec
+    videoClockThrottled             *prometheus.GaugeVec
+    memClockIdle                    *prometheus.GaugeVec
+    memClockUtilizationProduct      *prometheus.GaugeVec
+    atBoostClock                    *prometheus.GaugeVec
+    grClockChange                   *prometheus.GaugeVec
+    smGrClockDelta                  *prometheus.GaugeVec
+    clock                           *prometheus.GaugeVec
+    powerLimitConstraintsMin        *prometheus.GaugeVec
+    powerLimitConstraintsMax        *prometheus.GaugeVec
+    powerLimitManagement            *prometheus.GaugeVec
+    powerLimitEnforced              *prometheus.GaugeVec
+    powerLimitDelta                 *prometheus.GaugeVec
+    powerLimitSource                *prometheus.GaugeVec
+    powerManagementDefaultLimit     *prometheus.GaugeVec
+    tdpRatio                        *prometheus.GaugeVec
+    powerLimitDrift                 *prometheus.GaugeVec
+    powerUsageRatio                 *prometheus.GaugeVec
+    pciTxThroughput                 *prometheus.GaugeVec
+    pciRxThroughput                 *prometheus.GaugeVec
+    pciLinkGenerationCurrent        *prometheus.GaugeVec
+    pciLinkGenerationMax            *prometheus.GaugeVec
+    pciLinkWidthCurrent             *prometheus.GaugeVec
+    pciLinkWidthMax                 *prometheus.GaugeVec
+    pciLinkDegraded                 *prometheus.GaugeVec
+    healthScore                     *prometheus.GaugeVec
+    nvlinkLinksTotal                *prometheus.GaugeVec
+    nvlinkLinksActive               *prometheus.GaugeVec
+    videoEncoderCapacityH264        *prometheus.GaugeVec
+    videoEncoderCapacityHEVC        *prometheus.GaugeVec
+    autoBoostEnabled                *prometheus.GaugeVec
+    autoBoostDefaultEnabled         *prometheus.GaugeVec
+    clocksLockedByUser              *prometheus.GaugeVec
+    virtualizationMode              *prometheus.GaugeVec
+    virtualizationModeInfo          *prometheus.GaugeVec
+    inforomValid                    *prometheus.GaugeVec
+    inforomVersion                  *prometheus.GaugeVec
+    eccErrors                       *prometheus.GaugeVec
+    sramECCThresholdExceeded        *prometheus.GaugeVec
+    pcieAERCorrectable              *prometheus.Desc
+    pcieAERUncorrectable            *prometheus.Desc
+    aerTotals                       []prometheus.Metric
+    subCollectorError               *prometheus.GaugeVec
+    collectionErrors                *prometheus.CounterVec
+    deviceMetricsCollected          *prometheus.GaugeVec
+    deviceCollectionDuration        *prometheus.GaugeVec
+    vbiosInfo                       *prometheus.GaugeVec
+    boardInfo                       *prometheus.GaugeVec
+    processUsedMemory               *prometheus.GaugeVec
+    field                           *prometheus.GaugeVec
+}
+
+func NewCollector(provider Provider) *Collector {
+    return &Collector{
+        provider:         provider,
+        static:           make(map[string]*staticDeviceInfo),
+        idleSince:        make(map[string]time.Time),
+        prevGrClock:      make(map[string]clockSample),
+        prevThrottle:     make(map[string]throttleSample),
+        prevTemperature:  make(map[string]temperatureSample),
+        fanSpeedEMA:      make(map[string]float64),
+        prevPCIeCounters: make(map[string]pcieCounterSample),
+        deviceLabels:     make(map[string][]string),
+        errorLabels:      make(map[string]map[errorLabel]bool),
+        numDevices: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "num_devices",
+                Help:      "Number of GPU devices",
+            },
+        ),
+        duplicateUUID: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "duplicate_uuid",
+                Help:      "1 if two or more devices reported the same UUID during the last scrape (the duplicates get the device index appended), 0 otherwise",
+            },
+        ),
+        nvmlReinits: prometheus.NewCounter(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "nvml_reinit_total",
+                Help:      "Number of times NVML was re-initialized after losing the driver, e.g. after a driver reload",
+            },
+        ),
+        deviceCountChanged: prometheus.NewCounter(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "device_count_changed_total",
+                Help:      "Number of scrapes that saw a different number of devices than the previous one, e.g. after a GPU was hotplugged or removed",
+            },
+        ),
+        staleRecoveries: prometheus.NewCounter(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "suspend_resume_recovered_total",
+                Help:      "Number of times NVML was re-initialized because every device reading suddenly failed, as happens after a suspend and resume",
+            },
+        ),
+        scrapes: prometheus.NewCounter(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "collector_scrapes_total",
+                Help:      "Number of times the collector was scraped",
+            },
+        ),
+        cachedScrapes: prometheus.NewCounter(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "collector_cached_scrapes_total",
+                Help:      "Number of scrapes answered from the previous sweep of the devices because of -collector.backoff-on-load",
+            },
+        ),
+        warmupComplete: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "collector_warmup_complete",
+                Help:      "0 until the first scrape that collected every device without errors, 1 afterwards. Averages before that may be unreliable",
+            },
+        ),
+        lastSuccess: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "last_successful_collection_timestamp_seconds",
+                Help:      "Unix time of the last scrape that collected every device without errors, 0 if none did yet",
+            },
+        ),
+        averagingWindowTooShort: prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "averaging_window_too_short",
+                Help:      "1 if the time since the previous scrape exceeded -averaging-window, so the average metrics miss samples, 0 otherwise",
+            },
+        ),
+        usedMemory: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "memory_used_bytes",
+                Help:      "Memory used by the GPU device in bytes",
+            },
+            labels,
+        ),
+        totalMemory: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "memory_total_bytes",
+                Help:      "Total memory of the GPU device in bytes",
+            },
+            labels,
+        ),
+        freeMemory: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "memory_free_bytes",
+                Help:      "Memory of the GPU device available for allocations in bytes: total minus used minus reserved by the driver",
+            },
+            labels,
+        ),
+        memoryFragmentation: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "memory_fragmentation_ratio",
+                Help:      "Heuristic: fraction of the GPU device memory that NVML reports as neither used, reserved nor free",
+            },
+            labels,
+        ),
+        usedBar1Memory: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "bar1_memory_used_bytes",
+                Help:      "BAR1 Memory used by the GPU device in bytes",
+            },
+            labels,
+        ),
+        totalBar1Memory: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "bar1_memory_total_bytes",
+                Help:      "Total BAR1 memory of the GPU device in bytes",
+            },
+            labels,
+        ),
+        powerUsage: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_usage"),
+                Help:      "Power usage of the GPU device in " + *powerUnit,
+            },
+            labels,
+        ),
+        avgPowerUsage: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("avg_power_usage"),
+                Help:      "power usage for this GPU and its associated circuitry in " + *powerUnit + " averaged over the samples collected in the last window.",
+            },
+            averagingWindowLabels(),
+        ),
+        energyConsumption: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      energyMetricName("energy_consumption"),
+                Help:      "total energy consumption for this GPU in " + energyUnits[*energyUnit].suffix + " since the driver was last reloaded.",
+            },
+            labels,
+        ),
+        temperature: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "temperature_celsius",
+                Help:      "Temperature of the GPU device in celsius, per sensor (gpu, memory)",
+            },
+            withLabels("sensor"),
+        ),
+        temperatureThresholdShutDown: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "temperature_threshold_shutdown_celcius",
+                Help:      "Temperature slowdown threshold celsius",
+            },
+            labels,
+        ),
+        temperatureThresholdSlowDown: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "temperature_threshold_slowdown_celcius",
+                Help:      "Temperature slowdown threshold in celsius",
+            },
+            labels,
+        ),
+        thermalHeadroom: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "thermal_headroom_celsius",
+                Help:      "Slowdown threshold minus current temperature in celsius, i.e. how close the GPU is to thermal throttling",
+            },
+            labels,
+        ),
+        temperatureChange: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "temperature_change_celsius_per_second",
+                Help:      "Change of the GPU temperature since the previous scrape in celsius per second",
+            },
+            labels,
+        ),
+        temperatureThreshold: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "temperature_threshold_celsius",
+                Help:      "Temperature thresholds of the GPU device in celsius, per threshold type",
+            },
+            withLabels("threshold"),
+        ),
+        throttlingReason: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "throttling_reason",
+                Help:      "Most serious reason for the GPU being throttling",
+            },
+            labels,
+        ),
+        throttleReasonSupported: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "throttle_reason_supported",
+                Help:      "Whether the GPU can report the throttle reason, 1 if so",
+            },
+            withLabels("reason"),
+        ),
+        throttleReasonScrapes: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "throttle_reason_scrapes_total",
+                Help:      "Number of scrapes during which the GPU clocks were throttled for the reason",
+            },
+            withLabels("reason"),
+        ),
+        thermalThrottleSeconds: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "thermal_throttle_active_seconds",
+                Help:      "Seconds the GPU clocks spent throttled for a thermal reason, measured between scrapes",
+            },
+            labels,
+        ),
+        thermalThrottleEvents: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "thermal_throttle_events_total",
+                Help:      "Number of times thermal throttling of the GPU clocks started, as seen by scrapes",
+            },
+            labels,
+        ),
+        fanSpeed: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "fanspeed_percent",
+                Help:      "Fanspeed of the GPU device as a percent of its maximum",
+            },
+            labels,
+        ),
+        fanFailureSuspected: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "fan_failure_suspected",
+                Help:      "Heuristic: 1 if the fan of the GPU device reports (near) 0% while its temperature is above the slowdown threshold, 0 otherwise",
+            },
+            labels,
+        ),
+        encUsage: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "encoder_utilization_percent",
+                Help:      "EncoderUtilization returns the percent of time over the last sample period during which the GPU video encoder was being used.",
+            },
+            labels,
+        ),
+        decUsage: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "decoder_utilization_percent",
+                Help:      "DecoderUtilization returns the percent of time over the last sample period during which the GPU video decoder was being used.",
+            },
+            labels,
+        ),
+        jpegUsage: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "jpeg_utilization_percent",
+                Help:      "Percent of time over the last sample period during which the GPU JPEG decoder (NVJPG) was being used.",
+            },
+            labels,
+        ),
+        ofaUsage: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "ofa_utilization_percent",
+                Help:      "Percent of time over the last sample period during which the GPU optical flow accelerator (OFA) was being used.",
+            },
+            labels,
+        ),
+        GPUUtilizationRate: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "gpu_utilization_rate",
+                Help:      "GPUUtilizationRate returns the percent of time one of more kernels were executing on the GPU) averaged over the samples collected in the last `since` duration.",
+            },
+            labels,
+        ),
+        avgGPUUtilization: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "avg_gpu_utilization_percent",
+                Help:      "avgGPUUtilization returns the percent of time over the past window during which one or more kernels were executing on the GPU.",
+            },
+            averagingWindowLabels(),
+        ),
+        utilizationEMARatio: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "utilization_ema_ratio",
+                Help:      "Exponential moving average of the GPU utilization as a ratio, sampled every -utilization.sample-interval",
+            },
+            labels,
+        ),
+        memoryUtilizationRate: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "memory_utilization_rate",
+                Help:      "memoryUtilizationRate returns the percent of time over the past sample period during which global (Device) memory was being read or written.",
+            },
+            labels,
+        ),
+        computeMode: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "compute_mode",
+                Help:      "computeMode returns the compute mode of the device (prohibited, exclusive to thread, exclusive to process...).",
+            },
+            labels,
+        ),
+        performanceState: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "performance_state",
+                Help:      "PerformanceState returns the current performance state for the device (P0 maximum, P15 minimum, P32 unknown)",
+            },
+            labels,
+        ),
+        driverModelCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "driver_model_current",
+                Help:      "Current Windows driver model of the device (TCC=0, WDDM=1)",
+            },
+            labels,
+        ),
+        driverModelPending: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "driver_model_pending",
+                Help:      "Windows driver model of the device after the next reboot (TCC=0, WDDM=1)",
+            },
+            labels,
+        ),
+        migModeCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "mig_mode_current",
+                Help:      "1 if MIG mode is enabled on the GPU device, 0 otherwise",
+            },
+            labels,
+        ),
+        migModePending: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "mig_mode_pending",
+                Help:      "1 if MIG mode will be enabled on the GPU device after its next reset, 0 otherwise",
+            },
+            labels,
+        ),
+        grClockCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_gr_current_mhz",
+                Help:      "grClockCurrent returns the current speed of the graphics clock",
+            },
+            labels,
+        ),
+        grClockMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_gr_max_mhz",
+                Help:      "grClockMax returns the maximum speed of the graphics clock",
+            },
+            labels,
+        ),
+        grClockMin: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_gr_min_mhz",
+                Help:      "Minimum supported graphics clock of the GPU device in MHz",
+            },
+            labels,
+        ),
+        grClockCustomerMaxBoost: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_gr_customer_max_boost_mhz",
+                Help:      "Maximum customer boost graphics clock of the GPU device in MHz, which some cards cap below the max graphics clock",
+            },
+            labels,
+        ),
+        grClockRequested: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_gr_requested_mhz",
+                Help:      "Graphics clock requested for the GPU device in MHz (the applications clock target), to compare with the current one",
+            },
+            labels,
+        ),
+        SMClockCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_sm_current_mhz",
+                Help:      "smClockCurrent returns the current speed of the streaming multiprocessors (SM) clock",
+            },
+            labels,
+        ),
+        SMClockMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_sm_max_mhz",
+                Help:      "smClockMax returns the maximum speed of the streaming multiprocessors (SM) clock",
+            },
+            labels,
+        ),
+        memClockCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_mem_current_mhz",
+                Help:      "memClockCurrent returns the current speed of the memory clock",
+            },
+            labels,
+        ),
+        memClockMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_mem_max_mhz",
+                Help:      "memClockMax returns the maximum speed of the memory clock",
+            },
+            labels,
+        ),
+        memClockMin: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_mem_min_mhz",
+                Help:      "Minimum supported memory clock of the GPU device in MHz",
+            },
+            labels,
+        ),
+        videoClockCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_video_current_mhz",
+                Help:      "videolockCurrent returns the current speed of the video encoder/decoder clock",
+            },
+            labels,
+        ),
+        videoClockMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_video_max_mhz",
+                Help:      "memClockMax returns the maximum speed of the video encoder/decoder clock",
+            },
+            labels,
+        ),
+        grClockThrottled: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "gr_clock_throttled",
+                Help:      "1 if the graphics clock is significantly below its maximum while a throttle reason other than idle is active, 0 otherwise",
+            },
+            labels,
+        ),
+        memClockThrottled: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "mem_clock_throttled",
+                Help:      "1 if the memory clock is significantly below its maximum while a throttle reason other than idle is active, 0 otherwise",
+            },
+            labels,
+        ),
+        videoClockThrottled: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "video_clock_throttled",
+                Help:      "1 if the video (encoder/decoder) clock is significantly below its maximum while a throttle reason other than idle is active, 0 otherwise",
+            },
+            labels,
+        ),
+        memClockIdle: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "mem_clock_idle",
+                Help:      "1 if the memory clock is at the lowest clock the GPU device supports, 0 otherwise",
+            },
+            labels,
+        ),
+        memClockUtilizationProduct: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "memory_clock_utilization_product",
+                Help:      "Current memory clock in MHz times the memory utilization as a ratio, both read in the same pass over the GPU device: the effective memory clock",
+            },
+            labels,
+        ),
+        atBoostClock: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "at_boost_clock",
+                Help:      "1 if the graphics clock is at or above the maximum (boost) graphics clock of the GPU device, 0 otherwise",
+            },
+            labels,
+        ),
+        grClockChange: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "gr_clock_change_mhz_per_second",
+                Help:      "Change of the graphics clock since the previous scrape in MHz per second; large swings reveal clock instability",
+            },
+            labels,
+        ),
+        smGrClockDelta: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "sm_gr_clock_delta_mhz",
+                Help:      "Current SM clock minus current graphics clock of the GPU device in MHz",
+            },
+            labels,
+        ),
+        clock: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clock_mhz",
+                Help:      "Speed of the clock in MHz, type is graphics, sm, memory or video and kind is current, min, max, customer_max_boost or requested",
+            },
+            withLabels("type", "kind"),
+        ),
+        powerLimitConstraintsMin: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_limit_min"),
+                Help:      "PowerLimitConstraints retrieves information about possible values of power management limits on this device (min)",
+            },
+            labels,
+        ),
+        powerLimitConstraintsMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_limit_max"),
+                Help:      "PowerLimitConstraints retrieves information about possible values of power management limits on this device (max)",
+            },
+            labels,
+        ),
+        powerLimitManagement: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_limit_management"),
+                Help:      "The power limit defines the upper boundary for the card's power draw. If the card's total power draw reaches this limit the power management algorithm kicks in.",
+            },
+            labels,
+        ),
+        powerLimitEnforced: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_limit_enforced"),
+                Help:      "Effective power limit that the driver enforces after taking into account all limiters.  Note: This can be different from the management limit if other limits are set elsewhere This includes the out of band power limit interface",
+            },
+            labels,
+        ),
+        powerLimitDelta: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_limit_delta"),
+                Help:      "Enforced minus management power limit. Non-zero when another limiter, e.g. an out-of-band one, is active",
+            },
+            labels,
+        ),
+        powerLimitSource: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "power_limit_source",
+                Help:      "Limiter holding the GPU below its power limit (none=0, sw=1, hw_slowdown=2, hw_brake=3, oob=4)",
+            },
+            labels,
+        ),
+        powerManagementDefaultLimit: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      powerMetricName("power_management_default_limit"),
+                Help:      "PowerManagementDefaultLimit returns the power limit for this GPU and its associated circuitry in " + *powerUnit,
+            },
+            labels,
+        ),
+        tdpRatio: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "tdp_ratio",
+                Help:      "Power usage of the GPU device divided by its default power limit (TDP)",
+            },
+            labels,
+        ),
+        powerLimitDrift: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "power_limit_drift",
+                Help:      "1 if the power management limit of the GPU device differs from -power.expected-limit-watts by a watt or more, 0 otherwise",
+            },
+            labels,
+        ),
+        powerUsageRatio: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "power_usage_ratio",
+                Help:      "Power usage of the GPU device divided by its enforced power limit, clamped to [0, 1.2]",
+            },
+            labels,
+        ),
+        pciTxThroughput: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pci_throughput_tx_kilobytes_per_second",
+                Help:      "tx throughput in KB/s",
+            },
+            labels,
+        ),
+        pciRxThroughput: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pci_throughput_rx_kilobytes_per_second",
+                Help:      "rx throughput in KB/s",
+            },
+            labels,
+        ),
+        pciLinkGenerationCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pci_generation_current",
+                Help:      "current PCIe link generation",
+            },
+            labels,
+        ),
+        pciLinkGenerationMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pci_generation_max",
+                Help:      "Max PCIe link generation",
+            },
+            labels,
+        ),
+        pciLinkWidthCurrent: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pci_width_current",
+                Help:      "current PCIe link width",
+            },
+            labels,
+        ),
+        pciLinkWidthMax: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pci_width_max",
+                Help:      "Max PCIe link width",
+            },
+            labels,
+        ),
+        pciLinkDegraded: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "pcie_link_degraded",
+                Help:      "1 if the PCIe link runs below its maximum generation or width, 0 otherwise. Idle GPUs may lower the link generation to save power",
+            },
+            labels,
+        ),
+        healthScore: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "health_score",
+                Help:      healthScoreHelp(),
+            },
+            labels,
+        ),
+        nvlinkLinksTotal: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "nvlink_links_total",
+                Help:      "Number of NVLinks of the GPU device",
+            },
+            labels,
+        ),
+        nvlinkLinksActive: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "nvlink_links_active",
+                Help:      "Number of active NVLinks of the GPU device",
+            },
+            labels,
+        ),
+        videoEncoderCapacityH264: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "video_encoder_capacity_h264",
+                Help:      "Percentage of maximum encoder capacity (H264)",
+            },
+            labels,
+        ),
+        videoEncoderCapacityHEVC: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "video_encoder_capacity_hevc",
+                Help:      "Percentage of maximum encoder capacity (HEVC)",
+            },
+            labels,
+        ),
+        autoBoostEnabled: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "autoboost_enabled",
+                Help:      "1 if auto boosted clocks are currently enabled, 0 otherwise",
+            },
+            labels,
+        ),
+        autoBoostDefaultEnabled: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "autoboost_default_enabled",
+                Help:      "1 if auto boosted clocks are enabled by default, 0 otherwise",
+            },
+            labels,
+        ),
+        clocksLockedByUser: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "clocks_locked_by_user",
+                Help:      "1 if the applications clocks of the GPU device differ from their defaults, e.g. left over from nvidia-smi -ac or -lgc, 0 otherwise",
+            },
+            labels,
+        ),
+        virtualizationMode: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "virtualization_mode",
+                Help:      "Virtualization mode of the GPU device (none=0, pass-through=1, vgpu=2, host-vgpu=3, host-vsga=4)",
+            },
+            labels,
+        ),
+        virtualizationModeInfo: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "virtualization_mode_info",
+                Help:      "Virtualization mode of the GPU device as the mode label, value is always 1",
+            },
+            withLabels("mode"),
+        ),
+        inforomValid: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "inforom_valid",
+                Help:      "1 if the inforom of the GPU device passes its checksum, 0 if it is corrupt and the card needs an RMA",
+            },
+            labels,
+        ),
+        inforomVersion: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "inforom_version",
+                Help:      "Version of each inforom object of the GPU device as the version label, value is always 1",
+            },
+            withLabels("object", "version"),
+        ),
+        eccErrors: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "ecc_errors",
+                Help:      "Number of ECC errors of the GPU device. counter_type is volatile (since the last driver reload) or aggregate (lifetime)",
+            },
+            withLabels("error_type", "counter_type"),
+        ),
+        sramECCThresholdExceeded: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "sram_ecc_threshold_exceeded",
+                Help:      "1 if the uncorrectable SRAM ECC errors of the GPU device exceeded the threshold after which it needs replacing, 0 otherwise. Hopper and newer only",
+            },
+            labels,
+        ),
+        pcieAERCorrectable: prometheus.NewDesc(
+            prometheus.BuildFQName(namespace, "", "pcie_aer_correctable_total"),
+            "Correctable PCIe errors of the GPU device reported by Advanced Error Reporting since boot",
+            labels, nil,
+        ),
+        pcieAERUncorrectable: prometheus.NewDesc(
+            prometheus.BuildFQName(namespace, "", "pcie_aer_uncorrectable_total"),
+            "Uncorrectable (fatal and non-fatal) PCIe errors of the GPU device reported by Advanced Error Reporting since boot",
+            labels, nil,
+        ),
+        subCollectorError: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "subcollector_error",
+                Help:      "1 if the sub-collector failed for at least one device during the last scrape, 0 otherwise",
+            },
+            []string{"collector"},
+        ),
+        collectionErrors: prometheus.NewCounterVec(
+            prometheus.CounterOpts{
+                Namespace: namespace,
+                Name:      "collection_error",
+                Help:      "Number of failed device calls, by call and error class (unsupported, not_ready, gpu_lost, permission, unknown)",
+            },
+            []string{"minor_number", "call", "error_class"},
+        ),
+        deviceMetricsCollected: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "device_metrics_collected",
+                Help:      "Number of per-device metric values successfully collected for the device during the last scrape",
+            },
+            []string{"minor_number"},
+        ),
+        deviceCollectionDuration: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "device_collection_duration_seconds",
+                Help:      "Time it took to collect the device during the last scrape in seconds",
+            },
+            []string{"minor_number"},
+        ),
+        vbiosInfo: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "vbios_info",
+                Help:      "VBIOS version of the GPU device as a label, value is always 1",
+            },
+            []string{"uuid", "vbios_version"},
+        ),
+        boardInfo: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "board_info",
+                Help:      "Board part number and manufacturer of the GPU device as labels, value is always 1. NVML doesn't report the manufacturer, so it is empty unless the backend has it",
+            },
+            []string{"uuid", "part_number", "manufacturer"},
+        ),
+        processUsedMemory: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "process_used_memory_bytes",
+                Help:      "GPU memory used by a compute process running on the GPU device in bytes",
+            },
+            processLabels(),
+        ),
+        field: prometheus.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "field",
+                Help:      "Value of an NVML field of the GPU device requested with -nvml.extra-fields, by NVML_FI_* ID",
+            },
+            withLabels("field_id"),
+        ),
+        enabledCollectors: newEnabledCollectorsMetric(),
+    }
+}
+
+// newEnabledCollectorsMetric returns enabled_collectors_bitmap. The flags
+// don't change at runtime, so it's computed once.
+func newEnabledCollectorsMetric() prometheus.Metric {
+    bitmap, help := enabledCollectorsBitmap()
+    return prometheus.MustNewConstMetric(
+        prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "enabled_collectors_bitmap"), help, nil, nil),
+        prometheus.GaugeValue,
+        bitmap,
+    )
+}
+
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- c.numDevices.Desc()
+    ch <- c.duplicateUUID.Desc()
+    ch <- c.nvmlReinits.Desc()
+    ch <- c.deviceCountChanged.Desc()
+    ch <- c.staleRecoveries.Desc()
+    ch <- c.scrapes.Desc()
+    ch <- c.cachedScrapes.Desc()
+    ch <- c.averagingWindowTooShort.Desc()
+    ch <- c.warmupComplete.Desc()
+    ch <- c.lastSuccess.Desc()
+    ch <- c.enabledCollectors.Desc()
+    c.usedMemory.Describe(ch)
+    c.totalMemory.Describe(ch)
+    c.freeMemory.Describe(ch)
+    c.memoryFragmentation.Describe(ch)
+    c.usedBar1Memory.Describe(ch)
+    c.totalBar1Memory.Describe(ch)
+    c.powerUsage.Describe(ch)
+    c.avgPowerUsage.Describe(ch)
+    c.energyConsumption.Describe(ch)
+    c.temperature.Describe(ch)
+    c.temperatureThresholdShutDown.Describe(ch)
+    c.temperatureThresholdSlowDown.Describe(ch)
+    c.thermalHeadroom.Describe(ch)
+    c.temperatureChange.Describe(ch)
+    c.temperatureThreshold.Describe(ch)
+    c.throttlingReason.Describe(ch)
+    c.throttleReasonSupported.Describe(ch)
+    c.throttleReasonScrapes.Describe(ch)
+    c.thermalThrottleSeconds.Describe(ch)
+    c.thermalThrottleEvents.Describe(ch)
+    c.fanSpeed.Describe(ch)
+    c.fanFailureSuspected.Describe(ch)
+    c.encUsage.Describe(ch)
+    c.decUsage.Describe(ch)
+    c.jpegUsage.Describe(ch)
+    c.ofaUsage.Describe(ch)
+    c.GPUUtilizationRate.Describe(ch)
+    c.avgGPUUtilization.Describe(ch)
+    c.utilizationEMARatio.Describe(ch)
+    c.memoryUtilizationRate.Describe(ch)
+    c.computeMode.Describe(ch)
+    c.performanceState.Describe(ch)
+    c.driverModelCurrent.Describe(ch)
+    c.driverModelPending.Describe(ch)
+    c.migModeCurrent.Describe(ch)
+    c.migModePending.Describe(ch)
+    c.grClockCurrent.Describe(ch)
+    c.grClockMax.Describe(ch)
+    c.grClockMin.Describe(ch)
+    c.grClockCustomerMaxBoost.Describe(ch)
+    c.grClockRequested.Describe(ch)
+    c.SMClockCurrent.Describe(ch)
+    c.SMClockMax.Describe(ch)
+    c.memClockCurrent.Describe(ch)
+    c.memClockMax.Describe(ch)
+    c.memClockMin.Describe(ch)
+    c.videoClockCurrent.Describe(ch)
+    c.videoClockMax.Describe(ch)
+    c.grClockThrottled.Describe(ch)
+    c.memClockThrottled.Describe(ch)
+    c.videoClockThrottled.Describe(ch)
+    c.memClockIdle.Describe(ch)
+    c.memClockUtilizationProduct.Describe(ch)
+    c.atBoostClock.Describe(ch)
+    c.grClockChange.Describe(ch)
+    c.smGrClockDelta.Describe(ch)
+    c.clock.Describe(ch)
+    c.powerLimitConstraintsMin.Describe(ch)
+    c.powerLimitConstraintsMax.Describe(ch)
+    c.powerLimitManagement.Describe(ch)
+    c.powerLimitEnforced.Describe(ch)
+    c.powerLimitDelta.Describe(ch)
+    c.powerLimitSource.Describe(ch)
+    c.powerManagementDefaultLimit.Describe(ch)
+    c.tdpRatio.Describe(ch)
+    c.powerLimitDrift.Describe(ch)
+    c.powerUsageRatio.Describe(ch)
+    c.pciTxThroughput.Describe(ch)
+    c.pciRxThroughput.Describe(ch)
+    c.pciLinkGenerationCurrent.Describe(ch)
+    c.pciLinkGenerationMax.Describe(ch)
+    c.pciLinkWidthCurrent.Describe(ch)
+    c.pciLinkWidthMax.Describe(ch)
+    c.pciLinkDegraded.Describe(ch)
+    c.healthScore.Describe(ch)
+    c.nvlinkLinksTotal.Describe(ch)
+    c.nvlinkLinksActive.Describe(ch)
+    c.videoEncoderCapacityH264.Describe(ch)
+    c.videoEncoderCapacityHEVC.Describe(ch)
+    c.autoBoostEnabled.Describe(ch)
+    c.autoBoostDefaultEnabled.Describe(ch)
+    c.clocksLockedByUser.Describe(ch)
+    c.virtualizationMode.Describe(ch)
+    c.virtualizationModeInfo.Describe(ch)
+    c.inforomValid.Describe(ch)
+    c.inforomVersion.Describe(ch)
+    c.eccErrors.Describe(ch)
+    c.sramECCThresholdExceeded.Describe(ch)
+    ch <- c.pcieAERCorrectable
+    ch <- c.pcieAERUncorrectable
+    c.subCollectorError.Describe(ch)
+    c.collectionErrors.Describe(ch)
+    c.deviceMetricsCollected.Describe(ch)
+    c.deviceCollectionDuration.Describe(ch)
+    c.vbiosInfo.Describe(ch)
+    c.boardInfo.Describe(ch)
+    c.processUsedMemory.Describe(ch)
+    c.field.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+    // Only one Collect call in progress at a time.
+    c.Lock()
+    defer c.Unlock()
+
+    c.scrapes.Inc()
+    ch <- c.scrapes
+    cached := c.sweepCached(time.Now())
+    if cached {
+        c.cachedScrapes.Inc()
+    }
+    ch <- c.cachedScrapes
+    switch {
+    case cached:
+        for _, m := range c.sweptMetrics {
+            ch <- m
+        }
+    case *backoffOnLoad > 0:
+        c.sweepAndCache(ch)
+    default:
+        c.sweep(ch)
+    }
+}
+
+// sweep collects every device.
+func (c *Collector) sweep(ch chan<- prometheus.Metric) {
+    c.watch.start()
+    defer c.watch.finish()
+
+    ch <- c.enabledCollectors
+    c.checkAveragingWindow(time.Now())
+    ch <- c.averagingWindowTooShort
+
+    c.usedMemory.Reset()
+    c.totalMemory.Reset()
+    c.freeMemory.Reset()
+    c.memoryFragmentation.Reset()
+    c.usedBar1Memory.Reset()
+    c.totalBar1Memory.Reset()
+    c.powerUsage.Reset()
+    c.avgPowerUsage.Reset()
+    c.energyConsumption.Reset()
+    c.temperature.Reset()
+    c.temperatureThresholdShutDown.Reset()
+    c.temperatureThresholdSlowDown.Reset()
+    c.thermalHeadroom.Reset()
+    c.temperatureChange.Reset()
+    c.temperatureThreshold.Reset()
+    c.throttlingReason.Reset()
+    c.throttleReasonSupported.Reset()
+    c.fanSpeed.Reset()
+    c.fanFailureSuspected.Reset()
+    c.encUsage.Reset()
+    c.decUsage.Reset()
+    c.jpegUsage.Reset()
+    c.ofaUsage.Reset()
+    c.GPUUtilizationRate.Reset()
+    c.avgGPUUtilization.Reset()
+    c.utilizationEMARatio.Reset()
+    c.memoryUtilizationRate.Reset()
+    c.computeMode.Reset()
+    c.performanceState.Reset()
+    c.driverModelCurrent.Reset()
+    c.driverModelPending.Reset()
+    c.migModeCurrent.Reset()
+    c.migModePending.Reset()
+    c.grClockCurrent.Reset()
+    c.grClockMax.Reset()
+    c.grClockMin.Reset()
+    c.grClockCustomerMaxBoost.Reset()
+    c.grClockRequested.Reset()
+    c.SMClockCurrent.Reset()
+    c.SMClockMax.Reset()
+    c.memClockCurrent.Reset()
+    c.memClockMax.Reset()
+    c.memClockMin.Reset()
+    c.videoClockCurrent.Reset()
+    c.videoClockMax.Reset()
+    c.grClockThrottled.Reset()
+    c.memClockThrottled.Reset()
+    c.videoClockThrottled.Reset()
+    c.memClockIdle.Reset()
+    c.memClockUtilizationProduct.Reset()
+    c.atBoostClock.Reset()
+    c.grClockChange.Reset()
+    c.smGrClockDelta.Reset()
+    c.clock.Reset()
+    c.powerLimitConstraintsMin.Reset()
+    c.powerLimitConstraintsMax.Reset()
+    c.powerLimitManagement.Reset()
+    c.powerLimitEnforced.Reset()
+    c.powerLimitDelta.Reset()
+    c.powerLimitSource.Reset()
+    c.powerManagementDefaultLimit.Reset()
+    c.tdpRatio.Reset()
+    c.powerLimitDrift.Reset()
+    c.powerUsageRatio.Reset()
+    c.pciTxThroughput.Reset()
+    c.pciRxThroughput.Reset()
+    c.pciLinkGenerationCurrent.Reset()
+    c.pciLinkGenerationMax.Reset()
+    c.pciLinkWidthCurrent.Reset()
+    c.pciLinkWidthMax.Reset()
+    c.pciLinkDegraded.Reset()
+    c.healthScore.Reset()
+    c.nvlinkLinksTotal.Reset()
+    c.nvlinkLinksActive.Reset()
+    c.videoEncoderCapacityH264.Reset()
+    c.videoEncoderCapacityHEVC.Reset()
+    c.autoBoostEnabled.Reset()
+    c.autoBoostDefaultEnabled.Reset()
+    c.clocksLockedByUser.Reset()
+    c.virtualizationMode.Reset()
+    c.virtualizationModeInfo.Reset()
+    c.inforomValid.Reset()
+    c.inforomVersion.Reset()
+    c.eccErrors.Reset()
+    c.sramECCThresholdExceeded.Reset()
+    c.aerTotals = nil
+    c.subCollectorError.Reset()
+    c.deviceMetricsCollected.Reset()
+    c.deviceCollectionDuration.Reset()
+    c.vbiosInfo.Reset()
+    c.boardInfo.Reset()
+    c.processUsedMemory.Reset()
+    c.field.Reset()
+
+    numDevices, err := c.provider.DeviceCount()
+    if err != nil && c.recoverProvider(err) {
+        numDevices, err = c.provider.DeviceCount()
+    }
+    ch <- c.nvmlReinits
+    if err != nil {
+        log.Printf("DeviceCount() error: %v", err)
+        ch <- c.staleRecoveries
+        ch <- c.warmupComplete
+        ch <- c.lastSuccess
+        return
+    } else {
+        c.numDevices.Set(float64(numDevices))
+        ch <- c.numDevices
+    }
+    countChanged := c.haveDeviceCount && numDevices != c.prevDeviceCount
+    if countChanged {
+        log.Printf("Device count changed from %d to %d", c.prevDeviceCount, numDevices)
+        c.deviceCountChanged.Inc()
+    }
+    c.prevDeviceCount, c.haveDeviceCount = numDevices, true
+    ch <- c.deviceCountChanged
+
+    prevSeries := c.series
+    c.series = nil
+
+    // Misconfigured virtualization can hand out the same UUID to several
+    // devices, which would make their series collide.
+    seenUUIDs := make(map[string]bool)
+    duplicateUUID := false
+    seenMinors := make(map[string]bool)
+
+    subCollectors := c.subCollectors()
+    failed := make(map[string]bool)
+    totalCollected := 0
+
+    // Whether every device was read without errors, for warmupComplete and
+    // lastSuccess. Devices skipped below count as errors too.
+    devices, complete := c.deviceHandles(numDevices, false)
+    for _, d := range devices {
+        i, dev := d.index, d.dev
+        deviceStart := time.Now()
+
+        // Some passthrough setups report -1, or the same minor number for
+        // several devices, which would make their series collide. Those
+        // devices, and ones whose minor number can't be read, are exported
+        // as unknown-<index>, which can't clash with a real minor number.
+        fallback := fmt.Sprintf("unknown-%d", i)
+        minorNumber, err := dev.MinorNumber()
+        minor := strconv.Itoa(int(minorNumber))
+        if err != nil {
+            minor = fallback
+        } else if minorNumber > math.MaxInt32 || seenMinors[minor] {
+            log.Printf("Device %d reports invalid or duplicate minor number %d, exporting it as %s", i, int32(minorNumber), fallback)
+            minor = fallback
+        }
+        seenMinors[minor] = true
+        c.minor = minor
+        if err != nil {
+            c.callError("MinorNumber", err)
+            complete = false
+        }
+
+        uuid, err := dev.UUID()
+        if err != nil {
+            c.callError("UUID", err)
+            complete = false
+            continue
+        }
+        if seenUUIDs[uuid] {
+            disambiguated := fmt.Sprintf("%s-%d", uuid, i)
+            log.Printf("Device %d reports duplicate UUID %s, exporting it as %s", i, uuid, disambiguated)
+            uuid = disambiguated
+            duplicateUUID = true
+        }
+        seenUUIDs[uuid] = true
+
+        name, err := dev.Name()
+        if err != nil {
+            c.callError("Name", err)
+            complete = false
+            continue
+        }
+
+        if *labelVbios || *mode == "inventory" {
+            if info := c.staticInfo(dev, uuid); info.vbiosVersion != "" {
+                c.vbiosInfo.WithLabelValues(labelCase(uuid), info.vbiosVersion).Set(1)
+            }
+        }
+        if info := c.staticInfo(dev, uuid); info.partNumber != "" || info.manufacturer != "" {
+            c.boardInfo.WithLabelValues(labelCase(uuid), info.partNumber, info.manufacturer).Set(1)
+        }
+
+        c.uuid = uuid
+        instance := ""
+        if n, ok := c.provider.(instanceNamer); ok {
+            instance = n.Instance(uint(i))
+        }
+        lv := deviceLabelValues(minor, uuid, name, instance)
+        c.setDeviceLabels(uuid, lv)
+        c.collected = 0
+        c.readings = deviceReadings{}
+        idle := *activeOnly && *mode == "full" && c.deviceIdle(dev, uuid, time.Now())
+        for _, sc := range subCollectors {
+            if idle && !idleCollected[sc.name] {
+                continue
+            }
+            if err := sc.collect(dev, lv); err != nil {
+                failed[sc.name] = true
+            }
+        }
+        if score, ok := healthScore(c.readings); ok {
+            c.set(c.healthScore, lv, score)
+        }
+        c.deviceMetricsCollected.WithLabelValues(minor).Set(float64(c.collected))
+        c.deviceCollectionDuration.WithLabelValues(minor).Set(time.Since(deviceStart).Seconds())
+        totalCollected += c.collected
+    }
+
+    if numDevices > 0 && totalCollected == 0 && c.prevCollected > 0 {
+        c.recoverStaleProvider()
+    }
+    c.prevCollected = totalCollected
+    ch <- c.staleRecoveries
+
+    // Comparing on every sweep also catches a GPU swapped for another at the
+    // same count. Devices that couldn't be read this time keep their state.
+    if complete || countChanged {
+        c.forgetDevices(seenUUIDs, seenMinors)
+    }
+    if *markRemovedDevices {
+        markRemoved(prevSeries, seenUUIDs)
+    }
+    if *zeroOnError {
+        c.zeroMissing(prevSeries, seenUUIDs)
+    }
+
+    if duplicateUUID {
+        c.duplicateUUID.Set(1)
+    } else {
+        c.duplicateUUID.Set(0)
+    }
+    ch <- c.duplicateUUID
+
+    for _, sc := range subCollectors {
+        c.subCollectorError.WithLabelValues(sc.name).Set(boolToFloat(failed[sc.name]))
+    }
+    c.subCollectorError.Collect(ch)
+    c.collectionErrors.Collect(ch)
+    c.deviceMetricsCollected.Collect(ch)
+    c.deviceCollectionDuration.Collect(ch)
+    c.vbiosInfo.Collect(ch)
+    c.boardInfo.Collect(ch)
+    c.processUsedMemory.Collect(ch)
+    c.field.Collect(ch)
+
+    c.usedMemory.Collect(ch)
+    c.totalMemory.Collect(ch)
+    c.freeMemory.Collect(ch)
+    c.memoryFragmentation.Collect(ch)
+    c.usedBar1Memory.Collect(ch)
+    c.totalBar1Memory.Collect(ch)
+    c.powerUsage.Collect(ch)
+    c.avgPowerUsage.Collect(ch)
+    c.energyConsumption.Collect(ch)
+    c.temperature.Collect(ch)
+    c.temperatureThresholdShutDown.Collect(ch)
+    c.temperatureThresholdSlowDown.Collect(ch)
+    c.thermalHeadroom.Collect(ch)
+    c.temperatureChange.Collect(ch)
+    c.temperatureThreshold.Collect(ch)
+    c.throttlingReason.Collect(ch)
+    c.throttleReasonSupported.Collect(ch)
+    c.throttleReasonScrapes.Collect(ch)
+    c.thermalThrottleSeconds.Collect(ch)
+    c.thermalThrottleEvents.Collect(ch)
+    c.fanSpeed.Collect(ch)
+    c.fanFailureSuspected.Collect(ch)
+    c.encUsage.Collect(ch)
+    c.decUsage.Collect(ch)
+    c.jpegUsage.Collect(ch)
+    c.ofaUsage.Collect(ch)
+    c.GPUUtilizationRate.Collect(ch)
+    c.avgGPUUtilization.Collect(ch)
+    c.utilizationEMARatio.Collect(ch)
+    c.memoryUtilizationRate.Collect(ch)
+    c.computeMode.Collect(ch)
+    c.performanceState.Collect(ch)
+    c.driverModelCurrent.Collect(ch)
+    c.driverModelPending.Collect(ch)
+    c.migModeCurrent.Collect(ch)
+    c.migModePending.Collect(ch)
+    c.grClockCurrent.Collect(ch)
+    c.grClockMax.Collect(ch)
+    c.grClockMin.Collect(ch)
+    c.grClockCustomerMaxBoost.Collect(ch)
+    c.grClockRequested.Collect(ch)
+    c.SMClockCurrent.Collect(ch)
+    c.SMClockMax.Collect(ch)
+    c.memClockCurrent.Collect(ch)
+    c.memClockMax.Collect(ch)
+    c.memClockMin.Collect(ch)
+    c.videoClockCurrent.Collect(ch)
+    c.videoClockMax.Collect(ch)
+    c.grClockThrottled.Collect(ch)
+    c.memClockThrottled.Collect(ch)
+    c.videoClockThrottled.Collect(ch)
+    c.memClockIdle.Collect(ch)
+    c.memClockUtilizationProduct.Collect(ch)
+    c.atBoostClock.Collect(ch)
+    c.grClockChange.Collect(ch)
+    c.smGrClockDelta.Collect(ch)
+    c.clock.Collect(ch)
+    c.powerLimitConstraintsMin.Collect(ch)
+    c.powerLimitConstraintsMax.Collect(ch)
+    c.powerLimitManagement.Collect(ch)
+    c.powerLimitEnforced.Collect(ch)
+    c.powerLimitDelta.Collect(ch)
+    c.powerLimitSource.Collect(ch)
+    c.powerManagementDefaultLimit.Collect(ch)
+    c.tdpRatio.Collect(ch)
+    c.powerLimitDrift.Collect(ch)
+    c.powerUsageRatio.Collect(ch)
+    c.pciTxThroughput.Collect(ch)
+    c.pciRxThroughput.Collect(ch)
+    c.pciLinkGenerationCurrent.Collect(ch)
+    c.pciLinkGenerationMax.Collect(ch)
+    c.pciLinkWidthCurrent.Collect(ch)
+    c.pciLinkWidthMax.Collect(ch)
+    c.pciLinkDegraded.Collect(ch)
+    c.healthScore.Collect(ch)
+    c.nvlinkLinksTotal.Collect(ch)
+    c.nvlinkLinksActive.Collect(ch)
+    c.videoEncoderCapacityH264.Collect(ch)
+    c.videoEncoderCapacityHEVC.Collect(ch)
+    c.autoBoostEnabled.Collect(ch)
+    c.autoBoostDefaultEnabled.Collect(ch)
+    c.clocksLockedByUser.Collect(ch)
+    c.virtualizationMode.Collect(ch)
+    c.virtualizationModeInfo.Collect(ch)
+    c.inforomValid.Collect(ch)
+    c.inforomVersion.Collect(ch)
+    c.eccErrors.Collect(ch)
+    c.sramECCThresholdExceeded.Collect(ch)
+    for _, m := range c.aerTotals {
+        ch <- m
+    }
+
+    if complete && len(failed) == 0 {
+        c.warmupComplete.Set(1)
+        c.lastSuccess.SetToCurrentTime()
+    }
+    ch <- c.warmupComplete
+    ch <- c.lastSuccess
+}
+
+// checkAveragingWindow compares the time since the previous scrape with
+// the longest averaging window. A window shorter than the scrape interval
+// makes the average metrics cover only part of it.
+func (c *Collector) checkAveragingWindow(now time.Time) {
+    if !c.lastScrape.IsZero() {
+        interval := now.Sub(c.lastScrape)
+        window := longestAveragingWindow()
+        tooShort := interval > window
+        if tooShort && !c.averagingWarned {
+            log.Printf("Scrape interval %v is longer than the averaging window %v; the average metrics only cover part of it", interval, window)
+            c.averagingWarned = true
+        }
+        c.averagingWindowTooShort.Set(boolToFloat(tooShort))
+    }
+    c.lastScrape = now
+}
+
+// recoverProvider re-initializes the provider if err means it lost the
+// driver. Reports whether it did, in which case the failed call is worth
+// retrying.
+func (c *Collector) recoverProvider(err error) bool {
+    r, ok := c.provider.(reinitializer)
+    if !ok || !needsReinit(err) {
+        return false
+    }
+    log.Printf("Lost the driver (%v), re-initializing NVML", err)
+    if err := r.Reinitialize(); err != nil {
+        log.Printf("Couldn't re-initialize NVML: %v", err)
+        return false
+    }
+    c.nvmlReinits.Inc()
+    return true
+}
+
+// recoverStaleProvider re-initializes the provider after every device
+// reading failed although the previous scrape had some. After a suspend and
+// resume NVML can keep failing or returning stale values that way without
+// an error needsReinit recognizes, until it is re-initialized.
+func (c *Collector) recoverStaleProvider() {
+    r, ok := c.provider.(reinitializer)
+    if !ok {
+        return
+    }
+    log.Printf("Every device reading failed, re-initializing NVML")
+    if err := r.Reinitialize(); err != nil {
+        log.Printf("Couldn't re-initialize NVML: %v", err)
+        return
+    }
+    c.staleRecoveries.Inc()
+}
+
+// register registers c with reg. Unlike MustRegister it returns a plain
+// error naming what rather than panicking if c conflicts with a metric
+// already registered.
+func register(reg prometheus.Registerer, c prometheus.Collector, what string) error {
+    if err := reg.Register(c); err != nil {
+        return fmt.Errorf("registering %s: %v", what, err)
+    }
+    return nil
+}
+
+var (
+    applyFlagsOnce sync.Once
+    applyFlagsErr  error
+)
+
+// applyFlags checks the collector's flags once they are parsed and sets up
+// what they configure, such as the label set and the averaging windows.
+// Main and RegisterCollector both call it; it only takes effect once.
+func applyFlags() error {
+    applyFlagsOnce.Do(func() {
+        applyFlagsErr = checkCollectorFlags()
+    })
+    return applyFlagsErr
+}
+
+func checkCollectorFlags() error {
+    if *powerUnit != "watts" && *powerUnit != "milliwatts" {
+        return fmt.Errorf("invalid -power.unit %q: must be watts or milliwatts", *powerUnit)
+    }
+    if *expectedPowerLimit < 0 {
+        return fmt.Errorf("invalid -power.expected-limit-watts %v: must not be negative", *expectedPowerLimit)
+    }
+    if *pcieMode != "instant" && *pcieMode != "counted" {
+        return fmt.Errorf("invalid -pcie.mode %q: must be instant or counted", *pcieMode)
+    }
+    if *mode != "full" && *mode != "inventory" {
+        return fmt.Errorf("invalid -mode %q: must be full or inventory", *mode)
+    }
+    if _, ok := energyUnits[*energyUnit]; !ok {
+        return fmt.Errorf("invalid -energy.unit %q: must be joules, wh or kwh", *energyUnit)
+    }
+    switch *deviceSortBy {
+    case "index", "uuid", "pci-bus-id":
+    default:
+        return fmt.Errorf("invalid -device.sort-by %q: must be index, uuid or pci-bus-id", *deviceSortBy)
+    }
+    instances, err := parseInstances(*nvmlInstances)
+    if err != nil {
+        return fmt.Errorf("invalid -nvml.instances: %v", err)
+    }
+    if len(instances) > 0 {
+        if *backend != "nvidia-smi" {
+            return fmt.Errorf("-nvml.instances needs -backend=nvidia-smi: NVML can only be loaded once per process")
+        }
+        labels = append(labels, instanceLabel)
+    }
+    if *fanSmoothing < 0 || *fanSmoothing > 1 {
+        return fmt.Errorf("invalid -fan.smoothing %v: must be between 0 (off) and 1", *fanSmoothing)
+    }
+    if err := parseHealthWeights(*healthWeightsFlag); err != nil {
+        return fmt.Errorf("invalid -health.weights: %v", err)
+    }
+    if *labelsRoleMap != "" {
+        if err := deviceRoles.load(*labelsRoleMap); err != nil {
+            return fmt.Errorf("invalid -labels.role-map: %v", err)
+        }
+        deviceRoles.reloadOnSIGHUP()
+        labels = append(labels, roleLabel)
+    }
+    if err := dropLabels(*labelsDrop); err != nil {
+        return fmt.Errorf("invalid -labels.drop: %v", err)
+    }
+    windows, err := parseAveragingWindows(*averageDurations, *averageDuration)
+    if err != nil {
+        return fmt.Errorf("invalid -averaging-windows: %v", err)
+    }
+    averagingWindows = windows
+    if processNamePatterns, err = parseGlobs(*processNameAllowlist); err != nil {
+        return fmt.Errorf("invalid -process.name-allowlist: %v", err)
+    }
+    if extraFields, err = parseFieldIDs(*nvmlExtraFields); err != nil {
+        return fmt.Errorf("invalid -nvml.extra-fields: %v", err)
+    }
+    if metricsFilter, err = newMetricFilter(*metricsInclude, *metricsExclude); err != nil {
+        return fmt.Errorf("invalid metric filter: %v", err)
+    }
+    return nil
+}
+
+// nvmlInitialized is set once registerCollector initialized NVML, for
+// Shutdown.
+var nvmlInitialized bool
+
+// newProvider returns the Provider for -backend, initializing NVML for the
+// nvml backend, and registers the metrics about the backend with reg.
+func newProvider(reg prometheus.Registerer) (Provider, error) {
+    switch *backend {
+    case "nvml":
+        initStart := time.Now()
+        if err := gonvml.Initialize(); err != nil {
+            return nil, fmt.Errorf("couldn't initialize gonvml: %v. Make sure NVML is in the shared library search path", err)
+        }
+        nvmlInitialized = true
+
+        initDuration := prometheus.NewGauge(
+            prometheus.GaugeOpts{
+                Namespace: namespace,
+                Name:      "nvml_init_duration_seconds",
+                Help:      "Time it took to initialize NVML at startup in seconds",
+            },
+        )
+        initDuration.Set(time.Since(initStart).Seconds())
+        if err := register(reg, initDuration, "the NVML init duration metric"); err != nil {
+            return nil, err
+        }
+
+        driverVersion, err := gonvml.SystemDriverVersion()
+        if err != nil {
+            log.Printf("SystemDriverVersion() error: %v", err)
+        } else {
+            log.Printf("SystemDriverVersion(): %v", driverVersion)
+            if err := register(reg, newDriverInfo(driverVersion), "the driver info metric"); err != nil {
+                return nil, err
+            }
+        }
+
+        if NVMLVersion, err := gonvml.SystemNVMLVersion(); err != nil {
+            log.Printf("SystemNVMLVersion() error: %v", err)
+        } else {
+            log.Printf("SystemNVMLVersion(): %v", NVMLVersion)
+            if driverVersion != "" {
+                compatible := nvmlMatchesDriver(NVMLVersion, driverVersion)
+                if !compatible {
+                    log.Printf("NVML library %s doesn't come with driver %s, some metrics may be missing", NVMLVersion, driverVersion)
+                }
+                if err := register(reg, newNVMLDriverCompatible(compatible), "the NVML driver compatibility metric"); err != nil {
+                    return nil, err
+                }
+            }
+        }
+        return nvmlProvider{}, nil
+    case "nvidia-smi":
+        // Checked by applyFlags.
+        instances, _ := parseInstances(*nvmlInstances)
+        if len(instances) > 0 {
+            var providers []providerInstance
+            for _, inst := range instances {
+                providers = append(providers, providerInstance{inst.name, newNvidiaSmiProvider(inst.path)})
+            }
+            return newMultiProvider(providers), nil
+        }
+        return newNvidiaSmiProvider(*nvidiaSmiPath), nil
+    default:
+        return nil, fmt.Errorf("unknown -backend %q, must be nvml or nvidia-smi", *backend)
+    }
+}
+
+// registerCollector is RegisterCollector, returning the collector for the
+// endpoints Main serves.
+func registerCollector(reg prometheus.Registerer) (*Collector, error) {
+    if err := applyFlags(); err != nil {
+        return nil, err
+    }
+    provider, err := newProvider(reg)
+    if err != nil {
+        return nil, err
+    }
+
+    if r, ok := provider.(cudaDriverVersionReader); ok {
+        if version, err := r.CudaDriverVersion(); err != nil {
+            logCallError("CudaDriverVersion", err)
+        } else if err := register(reg, newCUDADriverVersion(version), "the CUDA driver version metric"); err != nil {
+            return nil, err
+        }
+    }
+
+    if len(extraFields) > 0 {
+        extraFields = probeExtraFields(provider, extraFields)
+    }
+
+    collector := NewCollector(provider)
+    if err := register(reg, collector, "the collector"); err != nil {
+        return nil, err
+    }
+    return collector, nil
+}
+
+// Main runs the exporter: it parses the flags, from the command line and
+// the environment, and serves the metrics until it is stopped. The
+// nvidia_gpu_prometheus_exporter command only calls Main.
+func Main() {
+    if err := setFlagsFromEnv(flags); err != nil {
+        log.Fatalf("%v", err)
+    }
+    flags.Parse(os.Args[1:])
+
+    if *addr == "" && *outputFile == "" && *otlpEndpoint == "" {
+        log.Fatalf("Nothing to do: -web.listen-address, -output.file and -otlp.endpoint are all empty")
+    }
+    if *listenInterface != "" && *addr != "" {
+        resolved, err := interfaceListenAddress(*addr, *listenInterface)
+        if err != nil {
+            log.Fatalf("%v", err)
+        }
+        log.Printf("Listening on %s of interface %s", resolved, *listenInterface)
+        *addr = resolved
+    }
+    if *addr != "" {
+        if err := validateListenAddress(*addr); err != nil {
+            log.Fatalf("%v", err)
+        }
+    }
+    if *outputFile != "" && *outputInterval <= 0 {
+        log.Fatalf("Invalid -output.interval %v: must be positive", *outputInterval)
+    }
+    var otlpURL string
+    if *otlpEndpoint != "" {
+        var err error
+        if otlpURL, err = otlpMetricsURL(*otlpEndpoint); err != nil {
+            log.Fatalf("%v", err)
+        }
+        if *otlpInterval <= 0 {
+            log.Fatalf("Invalid -otlp.interval %v: must be positive", *otlpInterval)
+        }
+    }
+    if *adminAddr != "" {
+        if err := validateListenAddress(*adminAddr); err != nil {
+            log.Fatalf("-web.admin-listen-address: %v", err)
+        }
+    }
+    if err := applyFlags(); err != nil {
+        log.Fatalf("%v", err)
+    }
+
+    if *validate {
+        os.Exit(validateMetrics())
+    }
+    if *printMetrics {
+        os.Exit(printMetricCatalog(os.Stdout))
+    }
+
+    // The default registry comes with the Go runtime and process collectors.
+    if !*enableGoMetrics {
+        prometheus.Unregister(prometheus.NewGoCollector())
+        prometheus.Unregister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+    }
+
+    collector, err := registerCollector(prometheus.DefaultRegisterer)
+    if err != nil {
+        log.Fatalf("%v", err)
+    }
+    defer Shutdown()
+
+    mux := http.NewServeMux()
+    adminMux := mux
+    if *adminAddr != "" {
+        adminMux = http.NewServeMux()
+    }
+    adminMux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        io.WriteString(w, "ok\n")
+    })
+    if *enableDebugEndpoint {
+        adminMux.Handle("/debug/nvml", debugNVMLHandler(collector))
+        adminMux.Handle("/clocks-status", clocksStatusHandler(collector))
+        adminMux.Handle("/reset-stats", resetStatsHandler(collector))
+    }
+    if *enablePprof {
+        // Registered explicitly: the exporter never serves http.DefaultServeMux,
+        // where net/http/pprof adds itself.
+        adminMux.HandleFunc("/debug/pprof/", pprof.Index)
+        adminMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+        adminMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+        adminMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+        adminMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+    }
+    // Serve on all other paths under addr
+    gatherer := metricsFilter.gatherer(prometheus.DefaultGatherer)
+    mux.Handle("/", metricsHandler(prometheus.DefaultRegisterer, gatherer))
+
+    if *utilizationSampleInterval > 0 && *mode != "inventory" {
+        stopSampler := collector.startUtilizationSampler(*utilizationSampleInterval)
+        defer stopSampler()
+    }
+
+    if *outputFile != "" {
+        stopOutput := startFileOutput(*outputFile, *outputInterval, gatherer)
+        defer stopOutput()
+    }
+
+    if otlpURL != "" {
+        stopOTLP := startOTLPExport(otlpURL, *otlpInterval, gatherer, collector.resetTime)
+        defer stopOTLP()
+    }
+
+    var servers []*http.Server
+    var listeners []net.Listener
+    if *addr != "" {
+        ln, err := net.Listen("tcp", *addr)
+        if err != nil {
+            log.Fatalf("Listen error: %v", err)
+        }
+        servers = append(servers, &http.Server{Handler: mux})
+        listeners = append(listeners, ln)
+    }
+    if *adminAddr != "" {
+        adminLn, err := net.Listen("tcp", *adminAddr)
+        if err != nil {
+            log.Fatalf("Admin listen error: %v", err)
+        }
+        servers = append(servers, &http.Server{Handler: adminMux})
+        listeners = append(listeners, adminLn)
+    }
+    if timeout := notifySystemdReady(); timeout > 0 {
+        stopWatchdog := collector.startWatchdogTicker(timeout)
+        defer stopWatchdog()
+    }
+    if err := serveAll(servers, listeners); err != nil {
+        log.Fatalf("Serve error: %v", err)
+    }
+}
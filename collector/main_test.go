@@ -0,0 +1,140 @@
+package collector
+
+import (
+    "errors"
+    "flag"
+    "testing"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// gatherByName gathers reg once and returns the metric families by name.
+func gatherByName(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+    mfs, err := reg.Gather()
+    if err != nil {
+        t.Fatal(err)
+    }
+    byName := make(map[string]*dto.MetricFamily)
+    for _, mf := range mfs {
+        byName[mf.GetName()] = mf
+    }
+    return byName
+}
+
+// withValidateFlags runs f with the flags -validate-metrics sets, restoring
+// them afterwards so other tests see the defaults.
+func withValidateFlags(t *testing.T, f func()) {
+    saved := make(map[string]string)
+    for _, name := range validateFlags {
+        saved[name] = flags.Lookup(name).Value.String()
+    }
+    for name := range validateSettings {
+        saved[name] = flags.Lookup(name).Value.String()
+    }
+    savedWindows, savedFields := averagingWindows, extraFields
+    defer func() {
+        for name, value := range saved {
+            flags.Set(name, value)
+        }
+        averagingWindows, extraFields = savedWindows, savedFields
+    }()
+
+    if err := setValidateFlags(); err != nil {
+        t.Fatal(err)
+    }
+    f()
+}
+
+func TestDescribedMetricsAreCollected(t *testing.T) {
+    withValidateFlags(t, func() {
+        c, mfs, err := gatherMock()
+        if err != nil {
+            t.Fatal(err)
+        }
+        missing, err := missingMetrics(c, mfs)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, name := range missing {
+            t.Errorf("%s is described but never collected", name)
+        }
+    })
+}
+
+// listProvider serves the given devices; nil ones fail to open.
+type listProvider []Device
+
+func (p listProvider) DeviceCount() (uint, error) {
+    return uint(len(p)), nil
+}
+
+func (p listProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    if p[index] == nil {
+        return nil, errors.New("NVML: Unknown Error")
+    }
+    return p[index], nil
+}
+
+// unnamedDevice is a mockDevice whose name can't be read, which skips it.
+type unnamedDevice struct {
+    numberedDevice
+}
+
+func (unnamedDevice) Name() (string, error) { return "", errors.New("NVML: Unknown Error") }
+
+func TestWarmupNeedsEveryDevice(t *testing.T) {
+    for _, tc := range []struct {
+        name     string
+        provider listProvider
+        complete bool
+    }{
+        {"all read", listProvider{mockDevice{}}, true},
+        {"skipped device", listProvider{mockDevice{}, unnamedDevice{numberedDevice{index: 1}}}, false},
+        {"no handle", listProvider{mockDevice{}, nil}, false},
+    } {
+        reg := prometheus.NewPedanticRegistry()
+        if err := reg.Register(NewCollector(tc.provider)); err != nil {
+            t.Fatal(err)
+        }
+        mfs := gatherByName(t, reg)
+        warmup := mfs[namespace+"_collector_warmup_complete"].GetMetric()[0].GetGauge().GetValue()
+        lastSuccess := mfs[namespace+"_last_successful_collection_timestamp_seconds"].GetMetric()[0].GetGauge().GetValue()
+        if (warmup == 1) != tc.complete || (lastSuccess > 0) != tc.complete {
+            t.Errorf("%s: warmup_complete = %v, last success at %v; want complete = %v", tc.name, warmup, lastSuccess, tc.complete)
+        }
+    }
+}
+
+// minorlessDevice is a numberedDevice whose minor number can't be read.
+type minorlessDevice struct {
+    numberedDevice
+}
+
+func (minorlessDevice) MinorNumber() (uint, error) { return 0, errors.New("NVML: Unknown Error") }
+
+func TestUnknownMinorNumber(t *testing.T) {
+    reg := prometheus.NewPedanticRegistry()
+    devices := listProvider{numberedDevice{index: 0}, minorlessDevice{numberedDevice{index: 1}}, numberedDevice{index: 0}}
+    if err := reg.Register(NewCollector(devices)); err != nil {
+        t.Fatal(err)
+    }
+    mfs := gatherByName(t, reg)
+    for _, minor := range []string{"0", "unknown-1", "unknown-2"} {
+        if !hasSeries(mfs[namespace+"_temperature_celsius"], "minor_number", minor) {
+            t.Errorf("no temperature series with minor_number=%q", minor)
+        }
+    }
+    errs := mfs[namespace+"_collection_error"]
+    if !hasSeries(errs, "call", "MinorNumber") || !hasSeries(errs, "minor_number", "unknown-1") {
+        t.Errorf("MinorNumber error not counted for minor_number=\"unknown-1\"")
+    }
+}
+
+func TestFlagsStayOffCommandLine(t *testing.T) {
+    flags.VisitAll(func(f *flag.Flag) {
+        if flag.Lookup(f.Name) != nil {
+            t.Errorf("-%s is registered on flag.CommandLine", f.Name)
+        }
+    })
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+    "fmt"
+    "time"
+)
+
+// The mock devices back -validate-metrics, -print-metrics and the tests.
+
+// mockProvider serves one mockDevice.
+type mockProvider struct{}
+
+func (mockProvider) DeviceCount() (uint, error) {
+    return 1, nil
+}
+
+func (mockProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    if index != 0 {
+        return nil, fmt.Errorf("no device with index %d", index)
+    }
+    return mockDevice{}, nil
+}
+
+// mockDevice supports every reading, including the optional ones, with
+// plausible values.
+type mockDevice struct{}
+
+func (mockDevice) MinorNumber() (uint, error)                        { return 0, nil }
+func (mockDevice) UUID() (string, error)                             { return "GPU-00000000-0000-0000-0000-000000000000", nil }
+func (mockDevice) Name() (string, error)                             { return "Mock GPU", nil }
+func (mockDevice) MemoryInfo() (uint64, uint64, error)               { return 16 << 30, 4 << 30, nil }
+func (mockDevice) Bar1MemoryInfo() (uint64, uint64, error)           { return 256 << 20, 8 << 20, nil }
+func (mockDevice) UtilizationRates() (uint, uint, error)             { return 50, 20, nil }
+func (mockDevice) PowerUsage() (uint, error)                         { return 150000, nil }
+func (mockDevice) AveragePowerUsage(time.Duration) (uint, error)     { return 145000, nil }
+func (mockDevice) TotalEnergyConsumption() (uint64, error)           { return 1000000, nil }
+func (mockDevice) PowerLimitConstraints() (uint, uint, error)        { return 100000, 300000, nil }
+func (mockDevice) PowerLimits() (uint, uint, error)                  { return 250000, 250000, nil }
+func (mockDevice) PowerManagementDefaultLimit() (uint, error)        { return 250000, nil }
+func (mockDevice) Temperature() (uint, error)                        { return 60, nil }
+func (mockDevice) TemperatureThresholds() (uint, uint, error)        { return 95, 90, nil }
+func (mockDevice) MostSeriousClocksThrottleReason() (uint, error)    { return 0x4, nil }
+func (mockDevice) CurrentClocksThrottleReasons() (uint64, error)     { return 0x4, nil }
+func (mockDevice) FanSpeed() (uint, error)                           { return 40, nil }
+func (mockDevice) EncoderUtilization() (uint, uint, error)           { return 0, 167000, nil }
+func (mockDevice) DecoderUtilization() (uint, uint, error)           { return 0, 167000, nil }
+func (mockDevice) AverageGPUUtilization(time.Duration) (uint, error) { return 48, nil }
+func (mockDevice) ComputeMode() (uint, error)                        { return 0, nil }
+func (mockDevice) PerformanceState() (uint, error)                   { return 0, nil }
+func (mockDevice) GrClock() (uint, error)                            { return 1400, nil }
+func (mockDevice) GrMaxClock() (uint, error)                         { return 1980, nil }
+func (mockDevice) SMClock() (uint, error)                            { return 1400, nil }
+func (mockDevice) SMMaxClock() (uint, error)                         { return 1980, nil }
+func (mockDevice) MemClock() (uint, error)                           { return 1593, nil }
+func (mockDevice) MemMaxClock() (uint, error)                        { return 1593, nil }
+func (mockDevice) VideoClock() (uint, error)                         { return 1260, nil }
+func (mockDevice) VideoMaxClock() (uint, error)                      { return 1710, nil }
+func (mockDevice) PcieTxThroughput() (uint, error)                   { return 1000, nil }
+func (mockDevice) PcieRxThroughput() (uint, error)                   { return 2000, nil }
+func (mockDevice) PcieGeneration() (uint, error)                     { return 4, nil }
+func (mockDevice) PcieMaxGeneration() (uint, error)                  { return 4, nil }
+func (mockDevice) PcieWidth() (uint, error)                          { return 16, nil }
+func (mockDevice) PcieMaxWidth() (uint, error)                       { return 16, nil }
+func (mockDevice) EncoderCapacity() (uint, uint, error)              { return 100, 100, nil }
+
+func (mockDevice) AutoBoostedClocksEnabled() (bool, bool, error)   { return true, true, nil }
+func (mockDevice) MemoryTemperature() (uint, error)                { return 70, nil }
+func (mockDevice) JpgUtilization() (uint, uint, error)             { return 0, 167000, nil }
+func (mockDevice) OfaUtilization() (uint, uint, error)             { return 0, 167000, nil }
+func (mockDevice) SramEccErrorThresholdExceeded() (bool, error)    { return false, nil }
+func (mockDevice) DriverModel() (uint, uint, error)                { return 1, 1, nil }
+func (mockDevice) VbiosVersion() (string, error)                   { return "96.00.00.00.01", nil }
+func (mockDevice) PciBusID() (string, error)                       { return "00000000:3B:00.0", nil }
+func (mockDevice) SupportedMemoryClocks() ([]uint, error)          { return []uint{1593, 405}, nil }
+func (mockDevice) BoardPartNumber() (string, error)                { return "900-00000-0000-000", nil }
+func (mockDevice) BoardManufacturer() (string, error)              { return "NVIDIA", nil }
+func (mockDevice) TemperatureThreshold(t uint) (uint, error)       { return 80 + t, nil }
+func (mockDevice) GrMaxCustomerBoostClock() (uint, error)          { return 1980, nil }
+func (mockDevice) MigMode() (uint, uint, error)                    { return 0, 1, nil }
+func (mockDevice) SupportedClocksThrottleReasons() (uint64, error) { return 0x1ff, nil }
+func (mockDevice) GrRequestedClock() (uint, error)                 { return 1800, nil }
+func (mockDevice) ApplicationsClocks() (uint, uint, error)         { return 1410, 1215, nil }
+func (mockDevice) DefaultApplicationsClocks() (uint, uint, error)  { return 1410, 1215, nil }
+func (mockDevice) VirtualizationMode() (uint, error)               { return 1, nil }
+func (mockDevice) PcieByteCounters() (uint64, uint64, error)       { return 1 << 30, 2 << 30, nil }
+func (mockDevice) InforomValid() (bool, error)                     { return true, nil }
+func (mockDevice) FieldValue(fieldID uint) (float64, error)        { return float64(fieldID), nil }
+
+func (mockDevice) TotalEccErrors() (uint64, uint64, uint64, uint64, error) {
+    return 2, 40, 0, 1, nil
+}
+
+func (mockDevice) RemappedRows() (uint, uint, bool, bool, error) {
+    return 1, 0, false, false, nil
+}
+
+func (mockDevice) ComputeRunningProcesses() ([]uint, []uint64, error) {
+    return []uint{1}, []uint64{1 << 30}, nil
+}
+
+func (mockDevice) MemoryInfoV2() (uint64, uint64, uint64, uint64, error) {
+    return 16 << 30, 512 << 20, 11 << 30, 4 << 30, nil
+}
+
+func (mockDevice) SupportedGraphicsClocks(memClock uint) ([]uint, error) {
+    return []uint{1980, 1410, 210}, nil
+}
+
+func (mockDevice) InforomVersion(object uint) (string, error) {
+    return []string{"G001.0000.03.03", "6.16", "N/A"}[object], nil
+}
+
+func (mockDevice) NvLinkState(link uint) (bool, error) {
+    if link >= 12 {
+        return false, errNotSupported
+    }
+    return link < 10, nil
+}
@@ -0,0 +1,175 @@
+package collector
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "os/exec"
+    "strconv"
+    "strings"
+)
+
+// nvidiaSmiFields are the --query-gpu fields read by the nvidia-smi backend,
+// in the column order smiDevice expects.
+var nvidiaSmiFields = []string{
+    "index",
+    "uuid",
+    "name",
+    "memory.total",
+    "memory.used",
+    "utilization.gpu",
+    "utilization.memory",
+    "power.draw",
+    "temperature.gpu",
+    "vbios_version",
+    "pci.bus_id",
+}
+
+// nvidiaSmiProvider is a fallback backend for hosts where NVML can't be
+// loaded by the exporter but the nvidia-smi binary works. It only covers
+// utilization, memory, power, temperature, the VBIOS version and the PCI bus
+// ID; everything else is reported as not supported.
+//
+// nvidia-smi is run once per scrape, from DeviceCount, and
+// DeviceHandleByIndex serves the rows read by that call.
+type nvidiaSmiProvider struct {
+    path    string
+    devices []smiDevice
+}
+
+func newNvidiaSmiProvider(path string) *nvidiaSmiProvider {
+    return &nvidiaSmiProvider{path: path}
+}
+
+func (p *nvidiaSmiProvider) DeviceCount() (uint, error) {
+    out, err := exec.Command(p.path,
+        "--query-gpu="+strings.Join(nvidiaSmiFields, ","),
+        "--format=csv,noheader,nounits").Output()
+    if err != nil {
+        return 0, fmt.Errorf("running %s: %v", p.path, err)
+    }
+    devices, err := parseNvidiaSmiOutput(out)
+    if err != nil {
+        return 0, err
+    }
+    p.devices = devices
+    return uint(len(devices)), nil
+}
+
+func (p *nvidiaSmiProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    if index >= uint(len(p.devices)) {
+        return nil, fmt.Errorf("no device with index %d", index)
+    }
+    return p.devices[index], nil
+}
+
+func parseNvidiaSmiOutput(out []byte) ([]smiDevice, error) {
+    r := csv.NewReader(bytes.NewReader(out))
+    r.TrimLeadingSpace = true
+    r.FieldsPerRecord = len(nvidiaSmiFields)
+    records, err := r.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("parsing nvidia-smi output: %v", err)
+    }
+    devices := make([]smiDevice, 0, len(records))
+    for _, record := range records {
+        devices = append(devices, smiDevice{fields: record})
+    }
+    return devices, nil
+}
+
+// smiDevice is one row of nvidia-smi --query-gpu output.
+type smiDevice struct {
+    unsupportedDevice
+    fields []string
+}
+
+// field returns the raw value of the given query field.
+func (d smiDevice) field(name string) (string, error) {
+    for i, f := range nvidiaSmiFields {
+        if f == name {
+            return strings.TrimSpace(d.fields[i]), nil
+        }
+    }
+    return "", fmt.Errorf("field %s not queried", name)
+}
+
+// value returns the numeric value of the given query field. nvidia-smi prints
+// "[N/A]" or "[Not Supported]" for readings a card doesn't have.
+func (d smiDevice) value(name string) (float64, error) {
+    s, err := d.field(name)
+    if err != nil {
+        return 0, err
+    }
+    if strings.HasPrefix(s, "[") {
+        return 0, errNotSupported
+    }
+    v, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        return 0, fmt.Errorf("parsing %s %q: %v", name, s, err)
+    }
+    return v, nil
+}
+
+// MinorNumber returns the nvidia-smi index, which matches the device minor
+// number on most hosts.
+func (d smiDevice) MinorNumber() (uint, error) {
+    v, err := d.value("index")
+    return uint(v), err
+}
+
+func (d smiDevice) UUID() (string, error) {
+    return d.field("uuid")
+}
+
+func (d smiDevice) Name() (string, error) {
+    return d.field("name")
+}
+
+// MemoryInfo converts nvidia-smi's MiB to bytes.
+func (d smiDevice) MemoryInfo() (uint64, uint64, error) {
+    total, err := d.value("memory.total")
+    if err != nil {
+        return 0, 0, err
+    }
+    used, err := d.value("memory.used")
+    if err != nil {
+        return 0, 0, err
+    }
+    return uint64(total) * 1024 * 1024, uint64(used) * 1024 * 1024, nil
+}
+
+func (d smiDevice) UtilizationRates() (uint, uint, error) {
+    gpu, err := d.value("utilization.gpu")
+    if err != nil {
+        return 0, 0, err
+    }
+    memory, err := d.value("utilization.memory")
+    if err != nil {
+        return 0, 0, err
+    }
+    return uint(gpu), uint(memory), nil
+}
+
+// PowerUsage converts nvidia-smi's watts to the milliwatts NVML reports.
+func (d smiDevice) PowerUsage() (uint, error) {
+    v, err := d.value("power.draw")
+    return uint(v * 1000), err
+}
+
+func (d smiDevice) Temperature() (uint, error) {
+    v, err := d.value("temperature.gpu")
+    return uint(v), err
+}
+
+func (d smiDevice) VbiosVersion() (string, error) {
+    v, err := d.field("vbios_version")
+    if err == nil && strings.HasPrefix(v, "[") {
+        return "", errNotSupported
+    }
+    return v, err
+}
+
+func (d smiDevice) PciBusID() (string, error) {
+    return d.field("pci.bus_id")
+}
@@ -0,0 +1,36 @@
+package collector
+
+// nvlinkMaxLinks is NVML_NVLINK_MAX_LINKS, the most links a device can have.
+const nvlinkMaxLinks = 18
+
+// nvlinkStateReader is implemented by devices reporting whether an NVLink is
+// active (nvmlDeviceGetNvLinkState). Links the device doesn't have return an
+// error.
+type nvlinkStateReader interface {
+    NvLinkState(link uint) (bool, error)
+}
+
+// collectNVLink counts the links of the device and how many are active.
+// Devices without NVLink export nothing.
+func (c *Collector) collectNVLink(dev Device, lv []string) error {
+    r, ok := dev.(nvlinkStateReader)
+    if !ok {
+        return nil
+    }
+    total, active := 0, 0
+    for link := uint(0); link < nvlinkMaxLinks; link++ {
+        up, err := r.NvLinkState(link)
+        if err != nil {
+            continue
+        }
+        total++
+        if up {
+            active++
+        }
+    }
+    if total > 0 {
+        c.set(c.nvlinkLinksTotal, lv, float64(total))
+        c.set(c.nvlinkLinksActive, lv, float64(active))
+    }
+    return nil
+}
@@ -0,0 +1,511 @@
+package collector
+
+// #cgo LDFLAGS: -ldl
+/*
+#include <stddef.h>
+#include <dlfcn.h>
+
+// NVML calls gonvml doesn't wrap. The library is opened a second time, which
+// hands back the instance gonvml already initialized, and every symbol is
+// looked up when it is called, so older drivers answer Function Not Found.
+
+typedef int nvmlReturn_t;
+typedef struct nvmlDevice_st* nvmlDevice_t;
+
+#define EXTRA_SUCCESS 0
+#define EXTRA_ERROR_NOT_SUPPORTED 3
+#define EXTRA_ERROR_INSUFFICIENT_SIZE 7
+#define EXTRA_ERROR_LIBRARY_NOT_FOUND 12
+#define EXTRA_ERROR_FUNCTION_NOT_FOUND 13
+#define EXTRA_ERROR_CORRUPTED_INFOROM 14
+
+static void *extraHandle;
+
+static void extraOpen(void) {
+    extraHandle = dlopen("libnvidia-ml.so.1", RTLD_LAZY);
+}
+
+static void *extraSym(const char *name) {
+    if (extraHandle == NULL) {
+        return NULL;
+    }
+    return dlsym(extraHandle, name);
+}
+
+static const char *extraErrorString(nvmlReturn_t ret) {
+    const char *(*f)(nvmlReturn_t) = extraSym("nvmlErrorString");
+    if (f == NULL) {
+        return "Unknown Error";
+    }
+    return f(ret);
+}
+
+static nvmlReturn_t extraDevice(unsigned int index, nvmlDevice_t *dev) {
+    nvmlReturn_t (*f)(unsigned int, nvmlDevice_t *) = extraSym("nvmlDeviceGetHandleByIndex_v2");
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    return f(index, dev);
+}
+
+static nvmlReturn_t extraSystemCudaDriverVersion(int *version) {
+    nvmlReturn_t (*f)(int *) = extraSym("nvmlSystemGetCudaDriverVersion");
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    return f(version);
+}
+
+// nvmlFieldValue_t.
+typedef struct {
+    unsigned int fieldId;
+    unsigned int scopeId;
+    long long timestamp;
+    long long latencyUsec;
+    int valueType;
+    nvmlReturn_t nvmlReturn;
+    union {
+        double dVal;
+        unsigned int uiVal;
+        unsigned long ulVal;
+        unsigned long long ullVal;
+        signed long long sllVal;
+    } value;
+} extraFieldValue;
+
+// extraGetFieldValue reads one NVML_FI_* field and converts it to a double
+// whatever its value type.
+static nvmlReturn_t extraGetFieldValue(unsigned int index, unsigned int fieldId, double *value) {
+    nvmlReturn_t (*f)(nvmlDevice_t, int, extraFieldValue *) = extraSym("nvmlDeviceGetFieldValues");
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    extraFieldValue fv = {0};
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    fv.fieldId = fieldId;
+    if ((ret = f(dev, 1, &fv)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    if (fv.nvmlReturn != EXTRA_SUCCESS) {
+        return fv.nvmlReturn;
+    }
+    switch (fv.valueType) {
+    case 0:
+        *value = fv.value.dVal;
+        break;
+    case 1:
+        *value = fv.value.uiVal;
+        break;
+    case 2:
+        *value = fv.value.ulVal;
+        break;
+    case 3:
+        *value = fv.value.ullVal;
+        break;
+    case 4:
+        *value = fv.value.sllVal;
+        break;
+    default:
+        return EXTRA_ERROR_NOT_SUPPORTED;
+    }
+    return EXTRA_SUCCESS;
+}
+
+// nvmlMemory_v2_t, from drivers with NVML 11.5 and later.
+typedef struct {
+    unsigned int version;
+    unsigned long long total;
+    unsigned long long reserved;
+    unsigned long long free;
+    unsigned long long used;
+} extraMemoryV2;
+
+static nvmlReturn_t extraGetMemoryInfoV2(unsigned int index, extraMemoryV2 *memory) {
+    nvmlReturn_t (*f)(nvmlDevice_t, extraMemoryV2 *) = extraSym("nvmlDeviceGetMemoryInfo_v2");
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    // NVML_STRUCT_VERSION(Memory, 2)
+    memory->version = (unsigned int)(sizeof(extraMemoryV2) | (2 << 24));
+    return f(dev, memory);
+}
+
+// nvmlEccSramErrorStatus_v1_t, from drivers with NVML 12.2 and later.
+typedef struct {
+    unsigned int version;
+    unsigned long long aggregateUncParity;
+    unsigned long long aggregateUncSecDed;
+    unsigned long long aggregateCor;
+    unsigned long long volatileUncParity;
+    unsigned long long volatileUncSecDed;
+    unsigned long long volatileCor;
+    unsigned long long aggregateUncBucketL2;
+    unsigned long long aggregateUncBucketSm;
+    unsigned long long aggregateUncBucketPcie;
+    unsigned long long aggregateUncBucketMcu;
+    unsigned long long aggregateUncBucketOther;
+    unsigned int bThresholdExceeded;
+} extraEccSramErrorStatus;
+
+static nvmlReturn_t extraGetSramEccErrorStatus(unsigned int index, extraEccSramErrorStatus *status) {
+    nvmlReturn_t (*f)(nvmlDevice_t, extraEccSramErrorStatus *) = extraSym("nvmlDeviceGetSramEccErrorStatus");
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    // NVML_STRUCT_VERSION(EccSramErrorStatus, 1)
+    status->version = (unsigned int)(sizeof(extraEccSramErrorStatus) | (1 << 24));
+    return f(dev, status);
+}
+
+// The helpers below call the device function name, looked up by name, on
+// the device at index. They're grouped by the shape of the call.
+
+static nvmlReturn_t extraGetUint2(const char *name, unsigned int index, unsigned int *a, unsigned int *b) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int *, unsigned int *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, a, b);
+}
+
+static nvmlReturn_t extraGetUint4(const char *name, unsigned int index, unsigned int *a, unsigned int *b, unsigned int *c, unsigned int *d) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int *, unsigned int *, unsigned int *, unsigned int *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, a, b, c, d);
+}
+
+static nvmlReturn_t extraGetUint(const char *name, unsigned int index, unsigned int *value) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, value);
+}
+
+static nvmlReturn_t extraGetUint64(const char *name, unsigned int index, unsigned long long *value) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned long long *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, value);
+}
+
+// extraGetUintAt passes arg, an index or an NVML enum, before the result.
+static nvmlReturn_t extraGetUintAt(const char *name, unsigned int index, unsigned int arg, unsigned int *value) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int, unsigned int *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, arg, value);
+}
+
+static nvmlReturn_t extraGetUintList(const char *name, unsigned int index, unsigned int *count, unsigned int *values) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int *, unsigned int *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, count, values);
+}
+
+static nvmlReturn_t extraGetString(const char *name, unsigned int index, char *buf, unsigned int length) {
+    nvmlReturn_t (*f)(nvmlDevice_t, char *, unsigned int) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, buf, length);
+}
+
+static nvmlReturn_t extraGetUintListAt(const char *name, unsigned int index, unsigned int arg, unsigned int *count, unsigned int *values) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int, unsigned int *, unsigned int *) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, arg, count, values);
+}
+
+static nvmlReturn_t extraGetStringAt(const char *name, unsigned int index, unsigned int arg, char *buf, unsigned int length) {
+    nvmlReturn_t (*f)(nvmlDevice_t, unsigned int, char *, unsigned int) = extraSym(name);
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev, arg, buf, length);
+}
+
+static nvmlReturn_t extraValidateInforom(unsigned int index) {
+    nvmlReturn_t (*f)(nvmlDevice_t) = extraSym("nvmlDeviceValidateInforom");
+    nvmlDevice_t dev;
+    nvmlReturn_t ret;
+    if (f == NULL) {
+        return EXTRA_ERROR_FUNCTION_NOT_FOUND;
+    }
+    if ((ret = extraDevice(index, &dev)) != EXTRA_SUCCESS) {
+        return ret;
+    }
+    return f(dev);
+}
+*/
+import "C"
+
+import (
+    "fmt"
+    "sync"
+)
+
+var extraOnce sync.Once
+
+// extraOpen opens the library for the calls below the first time it is
+// needed; by then gonvml has initialized it.
+func extraOpen() {
+    extraOnce.Do(func() { C.extraOpen() })
+}
+
+// extraError converts an NVML return code like gonvml does, so the error
+// classification applies. A missing library or symbol is errNotSupported.
+func extraError(ret C.nvmlReturn_t) error {
+    switch ret {
+    case C.EXTRA_SUCCESS:
+        return nil
+    case C.EXTRA_ERROR_LIBRARY_NOT_FOUND, C.EXTRA_ERROR_FUNCTION_NOT_FOUND:
+        return errNotSupported
+    }
+    return fmt.Errorf("NVML: %v", C.GoString(C.extraErrorString(ret)))
+}
+
+// Names of the NVML functions used, allocated once.
+var (
+    extraNameAutoBoostedClocksEnabled       = C.CString("nvmlDeviceGetAutoBoostedClocksEnabled")
+    extraNameJpgUtilization                 = C.CString("nvmlDeviceGetJpgUtilization")
+    extraNameOfaUtilization                 = C.CString("nvmlDeviceGetOfaUtilization")
+    extraNameDriverModel                    = C.CString("nvmlDeviceGetDriverModel")
+    extraNameSupportedMemoryClocks          = C.CString("nvmlDeviceGetSupportedMemoryClocks")
+    extraNameBoardPartNumber                = C.CString("nvmlDeviceGetBoardPartNumber")
+    extraNameTemperatureThreshold           = C.CString("nvmlDeviceGetTemperatureThreshold")
+    extraNameMaxCustomerBoostClock          = C.CString("nvmlDeviceGetMaxCustomerBoostClock")
+    extraNameNvLinkState                    = C.CString("nvmlDeviceGetNvLinkState")
+    extraNameMigMode                        = C.CString("nvmlDeviceGetMigMode")
+    extraNameSupportedClocksThrottleReasons = C.CString("nvmlDeviceGetSupportedClocksThrottleReasons")
+    extraNameSupportedGraphicsClocks        = C.CString("nvmlDeviceGetSupportedGraphicsClocks")
+    extraNameVirtualizationMode             = C.CString("nvmlDeviceGetVirtualizationMode")
+    extraNameInforomVersion                 = C.CString("nvmlDeviceGetInforomVersion")
+    extraNameDefaultApplicationsClock       = C.CString("nvmlDeviceGetDefaultApplicationsClock")
+    extraNameRemappedRows                   = C.CString("nvmlDeviceGetRemappedRows")
+)
+
+// extraUint2 calls an NVML device function returning two unsigned ints. NVML
+// enums and nvmlEnableState_t are int sized and come back the same way.
+func extraUint2(name *C.char, index uint) (uint, uint, error) {
+    extraOpen()
+    var a, b C.uint
+    ret := C.extraGetUint2(name, C.uint(index), &a, &b)
+    return uint(a), uint(b), extraError(ret)
+}
+
+// extraUint4 calls an NVML device function returning four unsigned ints.
+func extraUint4(name *C.char, index uint) (uint, uint, uint, uint, error) {
+    extraOpen()
+    var a, b, c, d C.uint
+    ret := C.extraGetUint4(name, C.uint(index), &a, &b, &c, &d)
+    return uint(a), uint(b), uint(c), uint(d), extraError(ret)
+}
+
+func extraCudaDriverVersion() (int, error) {
+    extraOpen()
+    var v C.int
+    ret := C.extraSystemCudaDriverVersion(&v)
+    return int(v), extraError(ret)
+}
+
+// extraFieldValue reads the NVML_FI_* field fieldID.
+func extraFieldValue(index uint, fieldID uint) (float64, error) {
+    extraOpen()
+    var v C.double
+    ret := C.extraGetFieldValue(C.uint(index), C.uint(fieldID), &v)
+    return float64(v), extraError(ret)
+}
+
+// extraUint calls an NVML device function returning an unsigned int or an
+// NVML enum.
+func extraUint(name *C.char, index uint) (uint, error) {
+    extraOpen()
+    var v C.uint
+    ret := C.extraGetUint(name, C.uint(index), &v)
+    return uint(v), extraError(ret)
+}
+
+// extraUint64 calls an NVML device function returning an unsigned long long.
+func extraUint64(name *C.char, index uint) (uint64, error) {
+    extraOpen()
+    var v C.ulonglong
+    ret := C.extraGetUint64(name, C.uint(index), &v)
+    return uint64(v), extraError(ret)
+}
+
+// extraUintAt calls an NVML device function taking arg and returning an
+// unsigned int.
+func extraUintAt(name *C.char, index uint, arg uint) (uint, error) {
+    extraOpen()
+    var v C.uint
+    ret := C.extraGetUintAt(name, C.uint(index), C.uint(arg), &v)
+    return uint(v), extraError(ret)
+}
+
+// extraListSize is the room first given for lists; NVML says how much it
+// needs if that's too little.
+const extraListSize = 128
+
+// extraUintList calls an NVML device function filling a list of unsigned
+// ints and its length.
+func extraUintList(name *C.char, index uint) ([]uint, error) {
+    return extraList(func(count *C.uint, values *C.uint) C.nvmlReturn_t {
+        return C.extraGetUintList(name, C.uint(index), count, values)
+    })
+}
+
+// extraUintListAt is extraUintList for functions taking arg first.
+func extraUintListAt(name *C.char, index uint, arg uint) ([]uint, error) {
+    return extraList(func(count *C.uint, values *C.uint) C.nvmlReturn_t {
+        return C.extraGetUintListAt(name, C.uint(index), C.uint(arg), count, values)
+    })
+}
+
+// extraList runs call with room for extraListSize values, and again with
+// the room NVML asks for if that's too little. A list that grew between the
+// two calls is cut to the room given.
+func extraList(call func(count *C.uint, values *C.uint) C.nvmlReturn_t) ([]uint, error) {
+    extraOpen()
+    values := make([]C.uint, extraListSize)
+    count := C.uint(len(values))
+    ret := call(&count, &values[0])
+    if ret == C.EXTRA_ERROR_INSUFFICIENT_SIZE && count > 0 {
+        values = make([]C.uint, count)
+        ret = call(&count, &values[0])
+    }
+    if err := extraError(ret); err != nil {
+        return nil, err
+    }
+    if int(count) > len(values) {
+        count = C.uint(len(values))
+    }
+    list := make([]uint, count)
+    for i := range list {
+        list[i] = uint(values[i])
+    }
+    return list, nil
+}
+
+// extraStringSize fits every NVML string the calls here return; the largest
+// is NVML_DEVICE_PART_NUMBER_BUFFER_SIZE.
+const extraStringSize = 80
+
+// extraString calls an NVML device function filling a string buffer.
+func extraString(name *C.char, index uint) (string, error) {
+    extraOpen()
+    var buf [extraStringSize]C.char
+    ret := C.extraGetString(name, C.uint(index), &buf[0], extraStringSize)
+    if err := extraError(ret); err != nil {
+        return "", err
+    }
+    return C.GoString(&buf[0]), nil
+}
+
+func extraMemoryInfoV2(index uint) (total, reserved, free, used uint64, err error) {
+    extraOpen()
+    var memory C.extraMemoryV2
+    ret := C.extraGetMemoryInfoV2(C.uint(index), &memory)
+    return uint64(memory.total), uint64(memory.reserved), uint64(memory.free), uint64(memory.used), extraError(ret)
+}
+
+func extraSramEccThresholdExceeded(index uint) (bool, error) {
+    extraOpen()
+    var status C.extraEccSramErrorStatus
+    ret := C.extraGetSramEccErrorStatus(C.uint(index), &status)
+    return status.bThresholdExceeded != 0, extraError(ret)
+}
+
+// extraStringAt is extraString for functions taking arg first.
+func extraStringAt(name *C.char, index uint, arg uint) (string, error) {
+    extraOpen()
+    var buf [extraStringSize]C.char
+    ret := C.extraGetStringAt(name, C.uint(index), C.uint(arg), &buf[0], extraStringSize)
+    if err := extraError(ret); err != nil {
+        return "", err
+    }
+    return C.GoString(&buf[0]), nil
+}
+
+// extraInforomValid reports a corrupted inforom as false rather than as an
+// error.
+func extraInforomValid(index uint) (bool, error) {
+    extraOpen()
+    ret := C.extraValidateInforom(C.uint(index))
+    if ret == C.EXTRA_ERROR_CORRUPTED_INFOROM {
+        return false, nil
+    }
+    return ret == C.EXTRA_SUCCESS, extraError(ret)
+}
+
+func extraAutoBoostedClocksEnabled(index uint) (bool, bool, error) {
+    enabled, defaultEnabled, err := extraUint2(extraNameAutoBoostedClocksEnabled, index)
+    return enabled != 0, defaultEnabled != 0, err
+}
@@ -0,0 +1,329 @@
+package collector
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "math"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// otlpDefaultPath is where OTLP/HTTP receivers take metrics.
+const otlpDefaultPath = "/v1/metrics"
+
+// otlpServiceName is the service.name resource attribute of what is pushed.
+const otlpServiceName = "nvidia_gpu_prometheus_exporter"
+
+// The types below are the subset of the OTLP ExportMetricsServiceRequest
+// used here, in its JSON encoding: 64 bit integers are strings.
+
+type otlpRequest struct {
+    ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+    Resource     otlpResource       `json:"resource"`
+    ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+    Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+    Scope   otlpScope    `json:"scope"`
+    Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+    Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+    Key   string       `json:"key"`
+    Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+    StringValue string `json:"stringValue"`
+}
+
+type otlpMetric struct {
+    Name        string         `json:"name"`
+    Description string         `json:"description,omitempty"`
+    Gauge       *otlpGauge     `json:"gauge,omitempty"`
+    Sum         *otlpSum       `json:"sum,omitempty"`
+    Histogram   *otlpHistogram `json:"histogram,omitempty"`
+    Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+type otlpGauge struct {
+    DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const otlpCumulative = 2
+
+type otlpSum struct {
+    DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+    AggregationTemporality int                   `json:"aggregationTemporality"`
+    IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+    DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+    AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+    DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+    Attributes        []otlpAttribute `json:"attributes,omitempty"`
+    StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+    TimeUnixNano      string          `json:"timeUnixNano"`
+    AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+    Attributes        []otlpAttribute `json:"attributes,omitempty"`
+    StartTimeUnixNano string          `json:"startTimeUnixNano"`
+    TimeUnixNano      string          `json:"timeUnixNano"`
+    Count             string          `json:"count"`
+    Sum               float64         `json:"sum"`
+    BucketCounts      []string        `json:"bucketCounts"`
+    ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpSummaryDataPoint struct {
+    Attributes     []otlpAttribute     `json:"attributes,omitempty"`
+    TimeUnixNano   string              `json:"timeUnixNano"`
+    Count          string              `json:"count"`
+    Sum            float64             `json:"sum"`
+    QuantileValues []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpQuantileValue struct {
+    Quantile float64 `json:"quantile"`
+    Value    float64 `json:"value"`
+}
+
+// otlpMetricsURL returns the URL to post to for the -otlp.endpoint value,
+// which may leave out the path or, for an address, the scheme too.
+func otlpMetricsURL(endpoint string) (string, error) {
+    u, err := url.Parse(endpoint)
+    if err != nil || u.Host == "" {
+        u, err = url.Parse("http://" + endpoint)
+    }
+    if err != nil || u.Host == "" {
+        return "", fmt.Errorf("Invalid -otlp.endpoint %q: want a URL like http://localhost:4318%s", endpoint, otlpDefaultPath)
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return "", fmt.Errorf("Invalid -otlp.endpoint %q: unsupported scheme %q", endpoint, u.Scheme)
+    }
+    if u.Path == "" || u.Path == "/" {
+        u.Path = otlpDefaultPath
+    }
+    return u.String(), nil
+}
+
+func otlpAttributes(labels []*dto.LabelPair) []otlpAttribute {
+    var attrs []otlpAttribute
+    for _, l := range labels {
+        attrs = append(attrs, otlpAttribute{Key: l.GetName(), Value: otlpAnyValue{StringValue: l.GetValue()}})
+    }
+    return attrs
+}
+
+// otlpFinite reports whether v can be sent: the JSON encoding has no NaN or
+// infinities, so such readings are left out like an unsupported one.
+func otlpFinite(v float64) bool {
+    return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func otlpNanos(t time.Time) string {
+    return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpMetricFrom converts one gathered family. Gauges and untyped metrics
+// become OTLP gauges, counters monotonic cumulative sums starting at start.
+// It returns false for families with nothing to send.
+func otlpMetricFrom(mf *dto.MetricFamily, start, now time.Time) (otlpMetric, bool) {
+    m := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+    startNanos, nowNanos := otlpNanos(start), otlpNanos(now)
+    switch mf.GetType() {
+    case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+        m.Gauge = &otlpGauge{}
+        for _, metric := range mf.GetMetric() {
+            v := metric.GetGauge().GetValue()
+            if mf.GetType() == dto.MetricType_UNTYPED {
+                v = metric.GetUntyped().GetValue()
+            }
+            if !otlpFinite(v) {
+                continue
+            }
+            m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberDataPoint{
+                Attributes:   otlpAttributes(metric.GetLabel()),
+                TimeUnixNano: nowNanos,
+                AsDouble:     v,
+            })
+        }
+        return m, len(m.Gauge.DataPoints) > 0
+    case dto.MetricType_COUNTER:
+        m.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+        for _, metric := range mf.GetMetric() {
+            if !otlpFinite(metric.GetCounter().GetValue()) {
+                continue
+            }
+            m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberDataPoint{
+                Attributes:        otlpAttributes(metric.GetLabel()),
+                StartTimeUnixNano: startNanos,
+                TimeUnixNano:      nowNanos,
+                AsDouble:          metric.GetCounter().GetValue(),
+            })
+        }
+        return m, len(m.Sum.DataPoints) > 0
+    case dto.MetricType_HISTOGRAM:
+        m.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+        for _, metric := range mf.GetMetric() {
+            h := metric.GetHistogram()
+            p := otlpHistogramDataPoint{
+                Attributes:        otlpAttributes(metric.GetLabel()),
+                StartTimeUnixNano: startNanos,
+                TimeUnixNano:      nowNanos,
+                Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+                Sum:               h.GetSampleSum(),
+                BucketCounts:      []string{},
+                ExplicitBounds:    []float64{},
+            }
+            // Prometheus buckets are cumulative, OTLP ones aren't, and the
+            // +Inf bucket is implied by the count.
+            var below uint64
+            for _, b := range h.GetBucket() {
+                if math.IsInf(b.GetUpperBound(), 1) {
+                    continue
+                }
+                p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+                p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-below, 10))
+                below = b.GetCumulativeCount()
+            }
+            p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-below, 10))
+            m.Histogram.DataPoints = append(m.Histogram.DataPoints, p)
+        }
+        return m, len(m.Histogram.DataPoints) > 0
+    case dto.MetricType_SUMMARY:
+        m.Summary = &otlpSummary{}
+        for _, metric := range mf.GetMetric() {
+            s := metric.GetSummary()
+            p := otlpSummaryDataPoint{
+                Attributes:     otlpAttributes(metric.GetLabel()),
+                TimeUnixNano:   nowNanos,
+                Count:          strconv.FormatUint(s.GetSampleCount(), 10),
+                Sum:            s.GetSampleSum(),
+                QuantileValues: []otlpQuantileValue{},
+            }
+            for _, q := range s.GetQuantile() {
+                p.QuantileValues = append(p.QuantileValues, otlpQuantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+            }
+            m.Summary.DataPoints = append(m.Summary.DataPoints, p)
+        }
+        return m, len(m.Summary.DataPoints) > 0
+    }
+    return m, false
+}
+
+// otlpRequestFrom converts the gathered families into one export request.
+// start gives the time each family's cumulative sums started at.
+func otlpRequestFrom(mfs []*dto.MetricFamily, start func(name string) time.Time, now time.Time) otlpRequest {
+    scope := otlpScopeMetrics{Scope: otlpScope{Name: otlpServiceName}, Metrics: []otlpMetric{}}
+    for _, mf := range mfs {
+        if m, ok := otlpMetricFrom(mf, start(mf.GetName()), now); ok {
+            scope.Metrics = append(scope.Metrics, m)
+        }
+    }
+    return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+        Resource: otlpResource{Attributes: []otlpAttribute{
+            {Key: "service.name", Value: otlpAnyValue{StringValue: otlpServiceName}},
+        }},
+        ScopeMetrics: []otlpScopeMetrics{scope},
+    }}}
+}
+
+// pushOTLP gathers the metrics from g and posts them to endpoint.
+func pushOTLP(client *http.Client, endpoint string, g prometheus.Gatherer, start func(name string) time.Time) error {
+    mfs, err := g.Gather()
+    if err != nil && len(mfs) == 0 {
+        return err
+    }
+    if err != nil {
+        // Like promhttp with ContinueOnError: send what was gathered.
+        log.Printf("Gathering metrics for OTLP: %v", err)
+    }
+
+    body, err := json.Marshal(otlpRequestFrom(mfs, start, time.Now()))
+    if err != nil {
+        return err
+    }
+    resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    msg, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+    }
+    return nil
+}
+
+// startOTLPExport pushes the metrics gathered from g to the OTLP/HTTP
+// receiver at endpoint every interval, starting right away, for stacks that
+// don't scrape. Pushes time out after the interval. Cumulative sums start
+// when the exporter did, or at resetTime for the families cleared since.
+// The returned function stops the exporter and waits for it to exit.
+func startOTLPExport(endpoint string, interval time.Duration, g prometheus.Gatherer, resetTime func(name string) time.Time) (stop func()) {
+    client := &http.Client{Timeout: interval}
+    exporterStart := time.Now()
+    start := func(name string) time.Time {
+        if t := resetTime(name); t.After(exporterStart) {
+            return t
+        }
+        return exporterStart
+    }
+    push := func() {
+        if err := pushOTLP(client, endpoint, g, start); err != nil {
+            log.Printf("Pushing metrics to %s: %v", endpoint, err)
+        }
+    }
+
+    quit := make(chan struct{})
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        push()
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-quit:
+                return
+            case <-ticker.C:
+                push()
+            }
+        }
+    }()
+
+    return func() {
+        close(quit)
+        <-done
+    }
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+    "log"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// startFileOutput writes the metrics gathered from g to path in the text
+// format every interval, starting right away, for hosts no Prometheus
+// server can reach: node_exporter's textfile collector or another shipper
+// picks the file up. The file is replaced atomically. The returned function
+// stops the writer and waits for it to exit.
+func startFileOutput(path string, interval time.Duration, g prometheus.Gatherer) (stop func()) {
+    write := func() {
+        if err := prometheus.WriteToTextfile(path, g); err != nil {
+            log.Printf("Writing metrics to %s: %v", path, err)
+        }
+    }
+
+    quit := make(chan struct{})
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        write()
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-quit:
+                return
+            case <-ticker.C:
+                write()
+            }
+        }
+    }()
+
+    return func() {
+        close(quit)
+        <-done
+    }
+}
@@ -0,0 +1,129 @@
+package collector
+
+import (
+    "bufio"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// processReader is implemented by devices listing the compute processes
+// running on them and the GPU memory each one uses
+// (nvmlDeviceGetComputeRunningProcesses).
+type processReader interface {
+    ComputeRunningProcesses() (pids []uint, usedMemory []uint64, err error)
+}
+
+// processLabels are the labels of the per-process metrics on top of the
+// device ones.
+func processLabels() []string {
+    if *resolveContainers {
+        return withLabels("pid", "process_name", "container_id")
+    }
+    return withLabels("pid", "process_name")
+}
+
+// otherProcesses is the pid and process_name of the series that sums up the
+// processes not selected by -process.name-allowlist or
+// -process.min-memory-bytes.
+const otherProcesses = "other"
+
+// processNamePatterns are the parsed -process.name-allowlist globs.
+var processNamePatterns []string
+
+// processName returns the command name of pid from /proc/<pid>/comm, or ""
+// if the process is gone.
+func processName(pid uint) string {
+    comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+    if err != nil {
+        return ""
+    }
+    return strings.TrimSpace(string(comm))
+}
+
+// ownProcessSeries reports whether a process gets its own series rather
+// than being summed into the other series. Without an allowlist or memory
+// threshold every process does.
+func ownProcessSeries(name string, usedMemory uint64) bool {
+    if len(processNamePatterns) == 0 && *processMinMemory == 0 {
+        return true
+    }
+    return matchAny(processNamePatterns, name) || (*processMinMemory > 0 && usedMemory >= *processMinMemory)
+}
+
+// containerIDPattern matches the 64 hex digit container IDs that Docker,
+// containerd and CRI-O put in cgroup paths, e.g.
+// /kubepods/burstable/pod<uid>/<id> or /system.slice/docker-<id>.scope.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// containerID returns the ID of the container pid runs in, from
+// /proc/<pid>/cgroup, or "" if it isn't in a container. A process that
+// exited since NVML listed it isn't an error.
+func containerID(pid uint) (string, error) {
+    f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+    if os.IsNotExist(err) {
+        return "", nil
+    }
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if ids := containerIDPattern.FindAllString(scanner.Text(), -1); len(ids) > 0 {
+            return ids[len(ids)-1], nil
+        }
+    }
+    return "", scanner.Err()
+}
+
+func (c *Collector) collectProcesses(dev Device, lv []string) error {
+    r, ok := dev.(processReader)
+    if !ok {
+        return nil
+    }
+    pids, usedMemory, err := r.ComputeRunningProcesses()
+    if err != nil {
+        c.callError("ComputeRunningProcesses", err)
+        var errs firstError
+        errs.record(err)
+        return errs.err
+    }
+
+    var other uint64
+    haveOther := false
+    for i, pid := range pids {
+        name := processName(pid)
+        if !ownProcessSeries(name, usedMemory[i]) {
+            other += usedMemory[i]
+            haveOther = true
+            continue
+        }
+        plv := append(lv, strconv.FormatUint(uint64(pid), 10), name)
+        if *resolveContainers {
+            id, err := containerID(pid)
+            if err != nil {
+                // Attribution is best effort; still export the process.
+                logCallError("containerID", err)
+            }
+            plv = append(plv, id)
+        }
+        // Not through c.set: process series come and go with the processes,
+        // so -collector.zero-on-error mustn't keep them alive.
+        c.processUsedMemory.WithLabelValues(plv...).Set(float64(usedMemory[i]))
+        c.collected++
+    }
+    if haveOther {
+        plv := append(lv, otherProcesses, otherProcesses)
+        if *resolveContainers {
+            plv = append(plv, "")
+        }
+        c.processUsedMemory.WithLabelValues(plv...).Set(float64(other))
+        c.collected++
+    }
+    return nil
+}
@@ -0,0 +1,641 @@
+package collector
+
+import (
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/cfsmp3/gonvml"
+)
+
+// errNotSupported is returned by backends for readings they can't provide.
+var errNotSupported = errors.New("not supported by this backend")
+
+// Provider enumerates the devices the collector reads from. NewCollector
+// takes any implementation; RegisterCollector picks the one for -backend.
+type Provider interface {
+    DeviceCount() (uint, error)
+    DeviceHandleByIndex(index uint) (Device, error)
+}
+
+// Device is the set of per-device readings the collector knows about. The
+// signatures follow gonvml; power values are in milliwatts and energy in
+// millijoules.
+type Device interface {
+    MinorNumber() (uint, error)
+    UUID() (string, error)
+    Name() (string, error)
+    MemoryInfo() (total uint64, used uint64, err error)
+    Bar1MemoryInfo() (total uint64, used uint64, err error)
+    UtilizationRates() (gpu uint, memory uint, err error)
+    PowerUsage() (uint, error)
+    AveragePowerUsage(since time.Duration) (uint, error)
+    TotalEnergyConsumption() (uint64, error)
+    PowerLimitConstraints() (min uint, max uint, err error)
+    PowerLimits() (management uint, enforced uint, err error)
+    PowerManagementDefaultLimit() (uint, error)
+    Temperature() (uint, error)
+    TemperatureThresholds() (shutdown uint, slowdown uint, err error)
+    MostSeriousClocksThrottleReason() (uint, error)
+    CurrentClocksThrottleReasons() (uint64, error)
+    FanSpeed() (uint, error)
+    EncoderUtilization() (uint, uint, error)
+    DecoderUtilization() (uint, uint, error)
+    AverageGPUUtilization(since time.Duration) (uint, error)
+    ComputeMode() (uint, error)
+    PerformanceState() (uint, error)
+    GrClock() (uint, error)
+    GrMaxClock() (uint, error)
+    SMClock() (uint, error)
+    SMMaxClock() (uint, error)
+    MemClock() (uint, error)
+    MemMaxClock() (uint, error)
+    VideoClock() (uint, error)
+    VideoMaxClock() (uint, error)
+    PcieTxThroughput() (uint, error)
+    PcieRxThroughput() (uint, error)
+    PcieGeneration() (uint, error)
+    PcieMaxGeneration() (uint, error)
+    PcieWidth() (uint, error)
+    PcieMaxWidth() (uint, error)
+    EncoderCapacity() (h264 uint, hevc uint, err error)
+}
+
+// reinitializer is implemented by providers that can recover from losing
+// their connection to the driver, e.g. after a driver reload.
+type reinitializer interface {
+    Reinitialize() error
+}
+
+// needsReinit reports whether err means NVML lost the driver and its handles
+// went stale, as happens after nvidia-smi --gpu-reset or a module reload.
+func needsReinit(err error) bool {
+    msg := err.Error()
+    return strings.Contains(msg, "Uninitialized") || strings.Contains(msg, "Driver Not Loaded")
+}
+
+// nvmlProvider reads devices through gonvml. gonvml.Initialize must have been
+// called before it is used.
+type nvmlProvider struct{}
+
+var _ cudaDriverVersionReader = nvmlProvider{}
+
+func (nvmlProvider) DeviceCount() (uint, error) {
+    n, err := gonvml.DeviceCount()
+    return uint(n), err
+}
+
+func (nvmlProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    dev, err := gonvml.DeviceHandleByIndex(index)
+    if err != nil {
+        return nil, err
+    }
+    return nvmlDevice{Device: dev, index: index}, nil
+}
+
+func (nvmlProvider) CudaDriverVersion() (int, error) {
+    return extraCudaDriverVersion()
+}
+
+func (nvmlProvider) Reinitialize() error {
+    gonvml.Shutdown()
+    return gonvml.Initialize()
+}
+
+// callRetryDelay is the pause before retrying a call that failed with a
+// transient error.
+const callRetryDelay = 50 * time.Millisecond
+
+// isTransient reports whether err is worth retrying right away: NVML
+// intermittently answers "Not Ready" or "Timeout" during the first calls
+// after initialization.
+func isTransient(err error) bool {
+    msg := err.Error()
+    return strings.Contains(msg, "Not Ready") || strings.Contains(msg, "Timeout")
+}
+
+// retryTransient retries call up to -collector.call-retries times while it
+// fails with a transient error. err is the result of the first attempt.
+func retryTransient(err error, call func() error) error {
+    for i := 0; i < *callRetries && err != nil && isTransient(err); i++ {
+        time.Sleep(callRetryDelay)
+        err = call()
+    }
+    return err
+}
+
+// The helpers below run call and retry it through retryTransient, one per
+// shape of result the nvmlDevice wrappers return.
+
+func retryUint(call func() (uint, error)) (uint, error) {
+    v, err := call()
+    err = retryTransient(err, func() error {
+        v, err = call()
+        return err
+    })
+    return v, err
+}
+
+func retryUint2(call func() (uint, uint, error)) (uint, uint, error) {
+    a, b, err := call()
+    err = retryTransient(err, func() error {
+        a, b, err = call()
+        return err
+    })
+    return a, b, err
+}
+
+func retryUint64(call func() (uint64, error)) (uint64, error) {
+    v, err := call()
+    err = retryTransient(err, func() error {
+        v, err = call()
+        return err
+    })
+    return v, err
+}
+
+func retryUint64x2(call func() (uint64, uint64, error)) (uint64, uint64, error) {
+    a, b, err := call()
+    err = retryTransient(err, func() error {
+        a, b, err = call()
+        return err
+    })
+    return a, b, err
+}
+
+func retryUint64x4(call func() (uint64, uint64, uint64, uint64, error)) (uint64, uint64, uint64, uint64, error) {
+    a, b, c, d, err := call()
+    err = retryTransient(err, func() error {
+        a, b, c, d, err = call()
+        return err
+    })
+    return a, b, c, d, err
+}
+
+func retryUint4(call func() (uint, uint, uint, uint, error)) (uint, uint, uint, uint, error) {
+    a, b, c, d, err := call()
+    err = retryTransient(err, func() error {
+        a, b, c, d, err = call()
+        return err
+    })
+    return a, b, c, d, err
+}
+
+func retryFloat64(call func() (float64, error)) (float64, error) {
+    v, err := call()
+    err = retryTransient(err, func() error {
+        v, err = call()
+        return err
+    })
+    return v, err
+}
+
+func retryBool(call func() (bool, error)) (bool, error) {
+    v, err := call()
+    err = retryTransient(err, func() error {
+        v, err = call()
+        return err
+    })
+    return v, err
+}
+
+func retryBool2(call func() (bool, bool, error)) (bool, bool, error) {
+    a, b, err := call()
+    err = retryTransient(err, func() error {
+        a, b, err = call()
+        return err
+    })
+    return a, b, err
+}
+
+func retryString(call func() (string, error)) (string, error) {
+    v, err := call()
+    err = retryTransient(err, func() error {
+        v, err = call()
+        return err
+    })
+    return v, err
+}
+
+func retryUintList(call func() ([]uint, error)) ([]uint, error) {
+    v, err := call()
+    err = retryTransient(err, func() error {
+        v, err = call()
+        return err
+    })
+    return v, err
+}
+
+// nvmlDevice adapts gonvml.Device to the Device interface. The embedded
+// gonvml.Device also makes any optional accessors gonvml provides (see
+// autoBoostReader and friends) visible through type assertions. The fork's
+// accessors use a mix of integer types, so the wrappers below normalise them,
+// and they retry transient failures. Readings gonvml doesn't wrap go through
+// the calls in nvml_extra.go, which need the device index.
+type nvmlDevice struct {
+    gonvml.Device
+    index uint
+}
+
+// The optional readings nvmlDevice provides. Listed so the build breaks
+// instead of the type assertions silently failing if a signature drifts.
+var (
+    _ autoBoostReader                = nvmlDevice{}
+    _ jpegUtilizationReader          = nvmlDevice{}
+    _ ofaUtilizationReader           = nvmlDevice{}
+    _ driverModelReader              = nvmlDevice{}
+    _ memoryTemperatureReader        = nvmlDevice{}
+    _ supportedMemoryClocksReader    = nvmlDevice{}
+    _ boardPartNumberReader          = nvmlDevice{}
+    _ temperatureThresholdReader     = nvmlDevice{}
+    _ customerBoostClockReader       = nvmlDevice{}
+    _ nvlinkStateReader              = nvmlDevice{}
+    _ migModeReader                  = nvmlDevice{}
+    _ supportedThrottleReasonsReader = nvmlDevice{}
+    _ requestedClockReader           = nvmlDevice{}
+    _ applicationsClocksReader       = nvmlDevice{}
+    _ fieldValueReader               = nvmlDevice{}
+    _ pcieByteCountersReader         = nvmlDevice{}
+    _ memoryInfoV2Reader             = nvmlDevice{}
+    _ supportedGraphicsClocksReader  = nvmlDevice{}
+    _ virtualizationModeReader       = nvmlDevice{}
+    _ inforomReader                  = nvmlDevice{}
+    _ eccErrorReader                 = nvmlDevice{}
+    _ remappedRowsReader             = nvmlDevice{}
+    _ sramECCThresholdReader         = nvmlDevice{}
+    _ processReader                  = nvmlDevice{}
+    _ vbiosVersionReader             = nvmlDevice{}
+    _ pciBusIDReader                 = nvmlDevice{}
+)
+
+func (d nvmlDevice) MinorNumber() (uint, error) {
+    return retryUint(d.Device.MinorNumber)
+}
+
+func (d nvmlDevice) MemoryInfo() (uint64, uint64, error) {
+    return retryUint64x2(d.Device.MemoryInfo)
+}
+
+func (d nvmlDevice) Bar1MemoryInfo() (uint64, uint64, error) {
+    return retryUint64x2(d.Device.Bar1MemoryInfo)
+}
+
+func (d nvmlDevice) UtilizationRates() (uint, uint, error) {
+    return retryUint2(d.Device.UtilizationRates)
+}
+
+func (d nvmlDevice) PowerUsage() (uint, error) {
+    return retryUint(d.Device.PowerUsage)
+}
+
+func (d nvmlDevice) AveragePowerUsage(since time.Duration) (uint, error) {
+    return retryUint(func() (uint, error) { return d.Device.AveragePowerUsage(since) })
+}
+
+func (d nvmlDevice) TotalEnergyConsumption() (uint64, error) {
+    return retryUint64(d.Device.TotalEnergyConsumption)
+}
+
+func (d nvmlDevice) PowerLimitConstraints() (uint, uint, error) {
+    return retryUint2(d.Device.PowerLimitConstraints)
+}
+
+func (d nvmlDevice) PowerLimits() (uint, uint, error) {
+    return retryUint2(d.Device.PowerLimits)
+}
+
+func (d nvmlDevice) PowerManagementDefaultLimit() (uint, error) {
+    return retryUint(d.Device.PowerManagementDefaultLimit)
+}
+
+func (d nvmlDevice) Temperature() (uint, error) {
+    return retryUint(d.Device.Temperature)
+}
+
+func (d nvmlDevice) TemperatureThresholds() (uint, uint, error) {
+    return retryUint2(d.Device.TemperatureThresholds)
+}
+
+func (d nvmlDevice) MostSeriousClocksThrottleReason() (uint, error) {
+    return retryUint(func() (uint, error) {
+        v, err := d.Device.MostSeriousClocksThrottleReason()
+        return uint(v), err
+    })
+}
+
+func (d nvmlDevice) CurrentClocksThrottleReasons() (uint64, error) {
+    return retryUint64(d.Device.CurrentClocksThrottleReasons)
+}
+
+func (d nvmlDevice) TotalEccErrors() (uint64, uint64, uint64, uint64, error) {
+    return retryUint64x4(d.Device.TotalEccErrors)
+}
+
+// ComputeRunningProcesses flattens gonvml's ComputeProcesses. gonvml returns
+// as many entries as it allocated room for, so the unused ones, with pid 0,
+// are dropped.
+func (d nvmlDevice) SramEccErrorThresholdExceeded() (bool, error) {
+    return retryBool(func() (bool, error) { return extraSramEccThresholdExceeded(d.index) })
+}
+
+func (d nvmlDevice) RemappedRows() (uint, uint, bool, bool, error) {
+    corrected, uncorrected, pending, failed, err := retryUint4(func() (uint, uint, uint, uint, error) {
+        return extraUint4(extraNameRemappedRows, d.index)
+    })
+    return corrected, uncorrected, pending != 0, failed != 0, err
+}
+
+func (d nvmlDevice) ComputeRunningProcesses() ([]uint, []uint64, error) {
+    procs, err := d.Device.ComputeProcesses()
+    err = retryTransient(err, func() error {
+        procs, err = d.Device.ComputeProcesses()
+        return err
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    var pids []uint
+    var usedMemory []uint64
+    for _, p := range procs {
+        if p.PID() == 0 {
+            continue
+        }
+        pids = append(pids, p.PID())
+        usedMemory = append(usedMemory, p.Memory())
+    }
+    return pids, usedMemory, nil
+}
+
+// VbiosVersion wraps gonvml's VBiosVersion.
+func (d nvmlDevice) VbiosVersion() (string, error) {
+    return retryString(d.Device.VBiosVersion)
+}
+
+// PciBusID wraps gonvml's BusID.
+func (d nvmlDevice) PciBusID() (string, error) {
+    return retryString(d.Device.BusID)
+}
+
+func (d nvmlDevice) AutoBoostedClocksEnabled() (bool, bool, error) {
+    return retryBool2(func() (bool, bool, error) { return extraAutoBoostedClocksEnabled(d.index) })
+}
+
+// JpgUtilization and OfaUtilization need a driver with NVML 12; older ones
+// report them as not supported.
+func (d nvmlDevice) JpgUtilization() (uint, uint, error) {
+    return retryUint2(func() (uint, uint, error) { return extraUint2(extraNameJpgUtilization, d.index) })
+}
+
+func (d nvmlDevice) OfaUtilization() (uint, uint, error) {
+    return retryUint2(func() (uint, uint, error) { return extraUint2(extraNameOfaUtilization, d.index) })
+}
+
+func (d nvmlDevice) DriverModel() (uint, uint, error) {
+    return retryUint2(func() (uint, uint, error) { return extraUint2(extraNameDriverModel, d.index) })
+}
+
+// nvmlFieldMemoryTemperature is NVML_FI_DEV_MEMORY_TEMP.
+const nvmlFieldMemoryTemperature = 82
+
+func (d nvmlDevice) MemoryTemperature() (uint, error) {
+    v, err := retryFloat64(func() (float64, error) { return extraFieldValue(d.index, nvmlFieldMemoryTemperature) })
+    return uint(v), err
+}
+
+func (d nvmlDevice) SupportedMemoryClocks() ([]uint, error) {
+    return retryUintList(func() ([]uint, error) { return extraUintList(extraNameSupportedMemoryClocks, d.index) })
+}
+
+func (d nvmlDevice) BoardPartNumber() (string, error) {
+    return retryString(func() (string, error) { return extraString(extraNameBoardPartNumber, d.index) })
+}
+
+func (d nvmlDevice) TemperatureThreshold(thresholdType uint) (uint, error) {
+    return retryUint(func() (uint, error) { return extraUintAt(extraNameTemperatureThreshold, d.index, thresholdType) })
+}
+
+// nvmlClockGraphics and nvmlClockMem are NVML_CLOCK_GRAPHICS and
+// NVML_CLOCK_MEM.
+const (
+    nvmlClockGraphics = 0
+    nvmlClockMem      = 2
+)
+
+func (d nvmlDevice) GrMaxCustomerBoostClock() (uint, error) {
+    return retryUint(func() (uint, error) { return extraUintAt(extraNameMaxCustomerBoostClock, d.index, nvmlClockGraphics) })
+}
+
+func (d nvmlDevice) NvLinkState(link uint) (bool, error) {
+    v, err := retryUint(func() (uint, error) { return extraUintAt(extraNameNvLinkState, d.index, link) })
+    return v != 0, err
+}
+
+func (d nvmlDevice) MigMode() (uint, uint, error) {
+    return retryUint2(func() (uint, uint, error) { return extraUint2(extraNameMigMode, d.index) })
+}
+
+func (d nvmlDevice) SupportedClocksThrottleReasons() (uint64, error) {
+    return retryUint64(func() (uint64, error) { return extraUint64(extraNameSupportedClocksThrottleReasons, d.index) })
+}
+
+// GrRequestedClock wraps gonvml's ApplicationClock, which reads the same
+// applications clock target as nvmlDeviceGetClock with
+// NVML_CLOCK_ID_APP_CLOCK_TARGET.
+func (d nvmlDevice) GrRequestedClock() (uint, error) {
+    return retryUint(func() (uint, error) { return d.Device.ApplicationClock(gonvml.ClockTypeGraphics) })
+}
+
+// ApplicationsClocks wraps gonvml's ApplicationClock for the graphics and
+// memory clocks.
+func (d nvmlDevice) ApplicationsClocks() (uint, uint, error) {
+    return retryUint2(func() (uint, uint, error) {
+        graphics, err := d.Device.ApplicationClock(gonvml.ClockTypeGraphics)
+        if err != nil {
+            return 0, 0, err
+        }
+        memory, err := d.Device.ApplicationClock(gonvml.ClockTypeMem)
+        return graphics, memory, err
+    })
+}
+
+func (d nvmlDevice) DefaultApplicationsClocks() (uint, uint, error) {
+    return retryUint2(func() (uint, uint, error) {
+        graphics, err := extraUintAt(extraNameDefaultApplicationsClock, d.index, nvmlClockGraphics)
+        if err != nil {
+            return 0, 0, err
+        }
+        memory, err := extraUintAt(extraNameDefaultApplicationsClock, d.index, nvmlClockMem)
+        return graphics, memory, err
+    })
+}
+
+// nvmlFieldPcieCountTxBytes and nvmlFieldPcieCountRxBytes are
+// NVML_FI_DEV_PCIE_COUNT_TX_BYTES and _RX_BYTES, from drivers with NVML 12
+// and later; the gonvml headers predate them.
+const (
+    nvmlFieldPcieCountTxBytes = 197
+    nvmlFieldPcieCountRxBytes = 198
+)
+
+func (d nvmlDevice) PcieByteCounters() (uint64, uint64, error) {
+    tx, err := d.FieldValue(nvmlFieldPcieCountTxBytes)
+    if err != nil {
+        return 0, 0, err
+    }
+    rx, err := d.FieldValue(nvmlFieldPcieCountRxBytes)
+    return uint64(tx), uint64(rx), err
+}
+
+func (d nvmlDevice) FieldValue(fieldID uint) (float64, error) {
+    return retryFloat64(func() (float64, error) { return extraFieldValue(d.index, fieldID) })
+}
+
+func (d nvmlDevice) MemoryInfoV2() (uint64, uint64, uint64, uint64, error) {
+    return retryUint64x4(func() (uint64, uint64, uint64, uint64, error) { return extraMemoryInfoV2(d.index) })
+}
+
+func (d nvmlDevice) SupportedGraphicsClocks(memClock uint) ([]uint, error) {
+    return retryUintList(func() ([]uint, error) { return extraUintListAt(extraNameSupportedGraphicsClocks, d.index, memClock) })
+}
+
+func (d nvmlDevice) VirtualizationMode() (uint, error) {
+    return retryUint(func() (uint, error) { return extraUint(extraNameVirtualizationMode, d.index) })
+}
+
+func (d nvmlDevice) InforomValid() (bool, error) {
+    return retryBool(func() (bool, error) { return extraInforomValid(d.index) })
+}
+
+func (d nvmlDevice) InforomVersion(object uint) (string, error) {
+    return retryString(func() (string, error) { return extraStringAt(extraNameInforomVersion, d.index, object) })
+}
+
+func (d nvmlDevice) FanSpeed() (uint, error) {
+    return retryUint(d.Device.FanSpeed)
+}
+
+func (d nvmlDevice) EncoderUtilization() (uint, uint, error) {
+    return retryUint2(d.Device.EncoderUtilization)
+}
+
+func (d nvmlDevice) DecoderUtilization() (uint, uint, error) {
+    return retryUint2(d.Device.DecoderUtilization)
+}
+
+func (d nvmlDevice) AverageGPUUtilization(since time.Duration) (uint, error) {
+    return retryUint(func() (uint, error) { return d.Device.AverageGPUUtilization(since) })
+}
+
+func (d nvmlDevice) ComputeMode() (uint, error) {
+    return retryUint(func() (uint, error) {
+        v, err := d.Device.ComputeMode()
+        return uint(v), err
+    })
+}
+
+func (d nvmlDevice) PerformanceState() (uint, error) {
+    return retryUint(d.Device.PerformanceState)
+}
+
+func (d nvmlDevice) GrClock() (uint, error) {
+    return retryUint(d.Device.GrClock)
+}
+
+func (d nvmlDevice) GrMaxClock() (uint, error) {
+    return retryUint(d.Device.GrMaxClock)
+}
+
+func (d nvmlDevice) SMClock() (uint, error) {
+    return retryUint(d.Device.SMClock)
+}
+
+func (d nvmlDevice) SMMaxClock() (uint, error) {
+    return retryUint(d.Device.SMMaxClock)
+}
+
+func (d nvmlDevice) MemClock() (uint, error) {
+    return retryUint(d.Device.MemClock)
+}
+
+func (d nvmlDevice) MemMaxClock() (uint, error) {
+    return retryUint(d.Device.MemMaxClock)
+}
+
+func (d nvmlDevice) VideoClock() (uint, error) {
+    return retryUint(d.Device.VideoClock)
+}
+
+func (d nvmlDevice) VideoMaxClock() (uint, error) {
+    return retryUint(d.Device.VideoMaxClock)
+}
+
+func (d nvmlDevice) PcieTxThroughput() (uint, error) {
+    return retryUint(d.Device.PcieTxThroughput)
+}
+
+func (d nvmlDevice) PcieRxThroughput() (uint, error) {
+    return retryUint(d.Device.PcieRxThroughput)
+}
+
+func (d nvmlDevice) PcieGeneration() (uint, error) {
+    return retryUint(d.Device.PcieGeneration)
+}
+
+func (d nvmlDevice) PcieMaxGeneration() (uint, error) {
+    return retryUint(d.Device.PcieMaxGeneration)
+}
+
+func (d nvmlDevice) PcieWidth() (uint, error) {
+    return retryUint(d.Device.PcieWidth)
+}
+
+func (d nvmlDevice) PcieMaxWidth() (uint, error) {
+    return retryUint(d.Device.PcieMaxWidth)
+}
+
+func (d nvmlDevice) EncoderCapacity() (uint, uint, error) {
+    return retryUint2(d.Device.EncoderCapacity)
+}
+
+// unsupportedDevice implements every device reading as errNotSupported.
+// Backends that only cover part of the metrics embed it and override what
+// they do provide.
+type unsupportedDevice struct{}
+
+func (unsupportedDevice) MinorNumber() (uint, error)                            { return 0, errNotSupported }
+func (unsupportedDevice) UUID() (string, error)                                 { return "", errNotSupported }
+func (unsupportedDevice) Name() (string, error)                                 { return "", errNotSupported }
+func (unsupportedDevice) MemoryInfo() (uint64, uint64, error)                   { return 0, 0, errNotSupported }
+func (unsupportedDevice) Bar1MemoryInfo() (uint64, uint64, error)               { return 0, 0, errNotSupported }
+func (unsupportedDevice) UtilizationRates() (uint, uint, error)                 { return 0, 0, errNotSupported }
+func (unsupportedDevice) PowerUsage() (uint, error)                             { return 0, errNotSupported }
+func (unsupportedDevice) AveragePowerUsage(time.Duration) (uint, error)         { return 0, errNotSupported }
+func (unsupportedDevice) TotalEnergyConsumption() (uint64, error)               { return 0, errNotSupported }
+func (unsupportedDevice) PowerLimitConstraints() (uint, uint, error)            { return 0, 0, errNotSupported }
+func (unsupportedDevice) PowerLimits() (uint, uint, error)                      { return 0, 0, errNotSupported }
+func (unsupportedDevice) PowerManagementDefaultLimit() (uint, error)            { return 0, errNotSupported }
+func (unsupportedDevice) Temperature() (uint, error)                            { return 0, errNotSupported }
+func (unsupportedDevice) TemperatureThresholds() (uint, uint, error)            { return 0, 0, errNotSupported }
+func (unsupportedDevice) MostSeriousClocksThrottleReason() (uint, error)        { return 0, errNotSupported }
+func (unsupportedDevice) CurrentClocksThrottleReasons() (uint64, error)         { return 0, errNotSupported }
+func (unsupportedDevice) FanSpeed() (uint, error)                               { return 0, errNotSupported }
+func (unsupportedDevice) EncoderUtilization() (uint, uint, error)               { return 0, 0, errNotSupported }
+func (unsupportedDevice) DecoderUtilization() (uint, uint, error)               { return 0, 0, errNotSupported }
+func (unsupportedDevice) AverageGPUUtilization(time.Duration) (uint, error)     { return 0, errNotSupported }
+func (unsupportedDevice) ComputeMode() (uint, error)                            { return 0, errNotSupported }
+func (unsupportedDevice) PerformanceState() (uint, error)                       { return 0, errNotSupported }
+func (unsupportedDevice) GrClock() (uint, error)                                { return 0, errNotSupported }
+func (unsupportedDevice) GrMaxClock() (uint, error)                             { return 0, errNotSupported }
+func (unsupportedDevice) SMClock() (uint, error)                                { return 0, errNotSupported }
+func (unsupportedDevice) SMMaxClock() (uint, error)                             { return 0, errNotSupported }
+func (unsupportedDevice) MemClock() (uint, error)                               { return 0, errNotSupported }
+func (unsupportedDevice) MemMaxClock() (uint, error)                            { return 0, errNotSupported }
+func (unsupportedDevice) VideoClock() (uint, error)                             { return 0, errNotSupported }
+func (unsupportedDevice) VideoMaxClock() (uint, error)                          { return 0, errNotSupported }
+func (unsupportedDevice) PcieTxThroughput() (uint, error)                       { return 0, errNotSupported }
+func (unsupportedDevice) PcieRxThroughput() (uint, error)                       { return 0, errNotSupported }
+func (unsupportedDevice) PcieGeneration() (uint, error)                         { return 0, errNotSupported }
+func (unsupportedDevice) PcieMaxGeneration() (uint, error)                      { return 0, errNotSupported }
+func (unsupportedDevice) PcieWidth() (uint, error)                              { return 0, errNotSupported }
+func (unsupportedDevice) PcieMaxWidth() (uint, error)                           { return 0, errNotSupported }
+func (unsupportedDevice) EncoderCapacity() (uint, uint, error)                  { return 0, 0, errNotSupported }
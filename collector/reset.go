@@ -0,0 +1,79 @@
+package collector
+
+import (
+    "io"
+    "log"
+    "net/http"
+    "time"
+)
+
+// resetCounters are the counter families resetStats clears.
+var resetCounters = map[string]bool{
+    namespace + "_throttle_reason_scrapes_total":   true,
+    namespace + "_thermal_throttle_active_seconds": true,
+    namespace + "_thermal_throttle_events_total":   true,
+}
+
+// resetStatsHandler clears the state the collector accumulates across
+// scrapes, e.g. after maintenance, without restarting the exporter. It only
+// accepts POST so a scraper or a browser pointed at it can't reset anything.
+func resetStatsHandler(c *Collector) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            w.Header().Set("Allow", http.MethodPost)
+            http.Error(w, "use POST to reset the collector stats", http.StatusMethodNotAllowed)
+            return
+        }
+        c.resetStats()
+        log.Printf("Collector stats reset by %s", r.RemoteAddr)
+        io.WriteString(w, "ok\n")
+    })
+}
+
+// resetStats forgets the throttle reason counts, the thermal throttling
+// time and onsets, the utilization and fan speed moving averages, the idle
+// tracking, the clock, temperature and PCIe counter readings kept for
+// change rates and the sweep cached by -collector.backoff-on-load.
+func (c *Collector) resetStats() {
+    c.Lock()
+    defer c.Unlock()
+
+    c.throttleReasonScrapes.Reset()
+    c.thermalThrottleSeconds.Reset()
+    c.thermalThrottleEvents.Reset()
+    for uuid := range c.utilizationEMA {
+        delete(c.utilizationEMA, uuid)
+    }
+    for uuid := range c.idleSince {
+        delete(c.idleSince, uuid)
+    }
+    for uuid := range c.prevGrClock {
+        delete(c.prevGrClock, uuid)
+    }
+    for uuid := range c.prevThrottle {
+        delete(c.prevThrottle, uuid)
+    }
+    for uuid := range c.prevTemperature {
+        delete(c.prevTemperature, uuid)
+    }
+    for uuid := range c.fanSpeedEMA {
+        delete(c.fanSpeedEMA, uuid)
+    }
+    for uuid := range c.prevPCIeCounters {
+        delete(c.prevPCIeCounters, uuid)
+    }
+    c.averagingWarned = false
+    c.sweptMetrics = nil
+    c.resetAt = time.Now()
+}
+
+// resetTime returns when resetStats last cleared the counter family name,
+// or the zero time if it never did, so OTLP can start its sums there.
+func (c *Collector) resetTime(name string) time.Time {
+    if !resetCounters[name] {
+        return time.Time{}
+    }
+    c.Lock()
+    defer c.Unlock()
+    return c.resetAt
+}
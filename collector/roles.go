@@ -0,0 +1,95 @@
+package collector
+
+import (
+    "bufio"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "strings"
+    "sync"
+    "syscall"
+)
+
+// roleLabel is the label holding the -labels.role-map role of a device.
+const roleLabel = "role"
+
+// unassignedRole is the role of devices missing from the role map.
+const unassignedRole = "unassigned"
+
+// roleMap maps device UUIDs to the role of the GPU in the cluster, e.g.
+// training or inference, read from -labels.role-map.
+type roleMap struct {
+    sync.RWMutex
+    path  string
+    roles map[string]string
+}
+
+var deviceRoles = &roleMap{}
+
+// parseRoleMap reads a role map file: one "<uuid> <role>" pair per line,
+// with blank lines and lines starting with # ignored.
+func parseRoleMap(path string) (map[string]string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    roles := make(map[string]string)
+    scanner := bufio.NewScanner(f)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) != 2 {
+            return nil, fmt.Errorf("%s:%d: want \"<uuid> <role>\", got %q", path, n, line)
+        }
+        roles[strings.ToLower(fields[0])] = fields[1]
+    }
+    return roles, scanner.Err()
+}
+
+// load (re)reads the role map from path.
+func (m *roleMap) load(path string) error {
+    roles, err := parseRoleMap(path)
+    if err != nil {
+        return err
+    }
+    m.Lock()
+    defer m.Unlock()
+    m.path = path
+    m.roles = roles
+    return nil
+}
+
+// role returns the role of the device with uuid.
+func (m *roleMap) role(uuid string) string {
+    m.RLock()
+    defer m.RUnlock()
+    if role, ok := m.roles[strings.ToLower(uuid)]; ok {
+        return role
+    }
+    return unassignedRole
+}
+
+// reloadOnSIGHUP rereads the role map whenever the process gets SIGHUP. A
+// map that fails to load is logged and the previous one kept.
+func (m *roleMap) reloadOnSIGHUP() {
+    sigs := make(chan os.Signal, 1)
+    signal.Notify(sigs, syscall.SIGHUP)
+    go func() {
+        for range sigs {
+            m.RLock()
+            path := m.path
+            m.RUnlock()
+            if err := m.load(path); err != nil {
+                log.Printf("Reloading -labels.role-map: %v, keeping the previous map", err)
+                continue
+            }
+            log.Printf("Reloaded -labels.role-map from %s", path)
+        }
+    }()
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+    "math"
+    "time"
+)
+
+// ema returns the exponential moving average after adding sample to prev.
+func ema(prev, sample, alpha float64) float64 {
+    return prev + alpha*(sample-prev)
+}
+
+// startUtilizationSampler samples the GPU utilization of the devices Collect
+// exports each interval and keeps an exponential moving average of it per
+// device UUID, exported as nvidia_gpu_utilization_ema_ratio. The smoothing
+// time constant is the averaging window used for the average metrics. The
+// returned function stops the sampler and waits for it to exit.
+func (c *Collector) startUtilizationSampler(interval time.Duration) (stop func()) {
+    alpha := 1 - math.Exp(-interval.Seconds()/averageDuration.Seconds())
+
+    c.Lock()
+    c.utilizationEMA = make(map[string]float64)
+    c.Unlock()
+
+    quit := make(chan struct{})
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-quit:
+                return
+            case <-ticker.C:
+                c.sampleUtilization(alpha)
+            }
+        }
+    }()
+
+    return func() {
+        close(quit)
+        <-done
+    }
+}
+
+// sampleUtilization picks the devices under the lock, the same ones in the
+// same order as Collect, but reads them without it so slow NVML calls don't
+// hold up scrapes.
+func (c *Collector) sampleUtilization(alpha float64) {
+    // Errors are left for Collect to log.
+    c.Lock()
+    numDevices, err := c.provider.DeviceCount()
+    var devices []indexedDevice
+    if err == nil {
+        devices, _ = c.deviceHandles(numDevices, true)
+    }
+    c.Unlock()
+    if err != nil {
+        return
+    }
+
+    samples := make(map[string]float64, len(devices))
+    for _, d := range devices {
+        uuid, err := d.dev.UUID()
+        if err != nil {
+            continue
+        }
+        utilizationGPU, _, err := d.dev.UtilizationRates()
+        if err != nil {
+            continue
+        }
+        samples[uuid] = float64(utilizationGPU) / 100
+    }
+
+    c.Lock()
+    defer c.Unlock()
+    for uuid, ratio := range samples {
+        if prev, ok := c.utilizationEMA[uuid]; ok {
+            ratio = ema(prev, ratio, alpha)
+        }
+        c.utilizationEMA[uuid] = ratio
+    }
+    for uuid := range c.utilizationEMA {
+        if _, ok := samples[uuid]; !ok {
+            delete(c.utilizationEMA, uuid)
+        }
+    }
+}
@@ -0,0 +1,16 @@
+package collector
+
+import "testing"
+
+func TestSamplerRespectsDeviceSingle(t *testing.T) {
+    defer func(single string) { *deviceSingle = single }(*deviceSingle)
+    *deviceSingle = "1"
+
+    count := uint(3)
+    c := NewCollector(countingProvider{&count})
+    c.utilizationEMA = make(map[string]float64)
+    c.sampleUtilization(1)
+    if _, ok := c.utilizationEMA["GPU-1"]; !ok || len(c.utilizationEMA) != 1 {
+        t.Errorf("sampled %v, want only GPU-1 with -device.single=1", c.utilizationEMA)
+    }
+}
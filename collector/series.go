@@ -0,0 +1,57 @@
+package collector
+
+import (
+    "log"
+    "math"
+    "strings"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// seriesRef identifies a per-device series set during a scrape.
+type seriesRef struct {
+    vec  *prometheus.GaugeVec
+    lv   []string
+    uuid string
+}
+
+// markRemoved sets the series that the previous scrape exported for devices
+// not seen in this one to NaN, so a removed GPU shows up as an explicit
+// transition rather than its series just ending. The marked series aren't
+// carried over, so they are gone from the following scrape.
+func markRemoved(prev []seriesRef, seen map[string]bool) {
+    logged := make(map[string]bool)
+    for _, s := range prev {
+        uuid := s.uuid
+        if seen[uuid] {
+            continue
+        }
+        if !logged[uuid] {
+            log.Printf("Device %s is gone, marking its series as removed", uuid)
+            logged[uuid] = true
+        }
+        s.vec.WithLabelValues(s.lv...).Set(math.NaN())
+    }
+}
+
+// zeroMissing sets to 0 the series that the previous scrape exported for a
+// device still present but that weren't set in this one, i.e. whose reading
+// failed. The zeroed series are carried over so they stay at 0 until the
+// reading succeeds again.
+func (c *Collector) zeroMissing(prev []seriesRef, seen map[string]bool) {
+    type seriesKey struct {
+        vec *prometheus.GaugeVec
+        lv  string
+    }
+    set := make(map[seriesKey]bool, len(c.series))
+    for _, s := range c.series {
+        set[seriesKey{s.vec, strings.Join(s.lv, "\xff")}] = true
+    }
+    for _, s := range prev {
+        if !seen[s.uuid] || set[seriesKey{s.vec, strings.Join(s.lv, "\xff")}] {
+            continue
+        }
+        s.vec.WithLabelValues(s.lv...).Set(0)
+        c.series = append(c.series, s)
+    }
+}
@@ -0,0 +1,134 @@
+package collector
+
+// vbiosVersionReader is implemented by devices that can report their VBIOS
+// version (nvmlDeviceGetVbiosVersion).
+type vbiosVersionReader interface {
+    VbiosVersion() (string, error)
+}
+
+// supportedMemoryClocksReader is implemented by devices listing the memory
+// clocks they support in MHz (nvmlDeviceGetSupportedMemoryClocks).
+type supportedMemoryClocksReader interface {
+    SupportedMemoryClocks() ([]uint, error)
+}
+
+// supportedGraphicsClocksReader is implemented by devices listing the
+// graphics clocks they support in MHz at a memory clock
+// (nvmlDeviceGetSupportedGraphicsClocks).
+type supportedGraphicsClocksReader interface {
+    SupportedGraphicsClocks(memClock uint) ([]uint, error)
+}
+
+// boardPartNumberReader and boardManufacturerReader are implemented by
+// devices reporting their board part number (nvmlDeviceGetBoardPartNumber)
+// and manufacturer. Consumer cards usually don't have them. NVML has no
+// manufacturer call, so nvmlDevice only implements the part number.
+type boardPartNumberReader interface {
+    BoardPartNumber() (string, error)
+}
+
+type boardManufacturerReader interface {
+    BoardManufacturer() (string, error)
+}
+
+// staticDeviceInfo holds device attributes that don't change while the
+// driver is loaded. They are read the first time a device is seen and served
+// from Collector.static afterwards.
+type staticDeviceInfo struct {
+    vbiosVersion string
+    pciBusID     string
+    partNumber   string
+    manufacturer string
+    minMemClock  uint // 0 if the supported memory clocks can't be read
+    minGrClock   uint // 0 if the supported graphics clocks can't be read
+
+    supportedThrottleReasons     uint64
+    haveSupportedThrottleReasons bool
+}
+
+// staticInfo returns the cached static attributes of dev, reading them on
+// first use. Must be called with the collector locked.
+func (c *Collector) staticInfo(dev Device, uuid string) *staticDeviceInfo {
+    if info, ok := c.static[uuid]; ok {
+        return info
+    }
+
+    info := &staticDeviceInfo{}
+    if r, ok := dev.(vbiosVersionReader); ok {
+        if v, err := r.VbiosVersion(); err != nil {
+            logCallError("VbiosVersion", err)
+        } else {
+            info.vbiosVersion = v
+        }
+    }
+
+    if r, ok := dev.(pciBusIDReader); ok {
+        if v, err := r.PciBusID(); err != nil {
+            logCallError("PciBusID", err)
+        } else {
+            info.pciBusID = v
+        }
+    }
+
+    if r, ok := dev.(boardPartNumberReader); ok {
+        if v, err := r.BoardPartNumber(); err != nil {
+            logCallError("BoardPartNumber", err)
+        } else {
+            info.partNumber = v
+        }
+    }
+    if r, ok := dev.(boardManufacturerReader); ok {
+        if v, err := r.BoardManufacturer(); err != nil {
+            logCallError("BoardManufacturer", err)
+        } else {
+            info.manufacturer = v
+        }
+    }
+    if r, ok := dev.(supportedMemoryClocksReader); ok {
+        if clocks, err := r.SupportedMemoryClocks(); err != nil {
+            logCallError("SupportedMemoryClocks", err)
+        } else {
+            for _, clock := range clocks {
+                if info.minMemClock == 0 || clock < info.minMemClock {
+                    info.minMemClock = clock
+                }
+            }
+            info.minGrClock = minGraphicsClock(dev, clocks)
+        }
+    }
+
+    if r, ok := dev.(supportedThrottleReasonsReader); ok {
+        if mask, err := r.SupportedClocksThrottleReasons(); err != nil {
+            logCallError("SupportedClocksThrottleReasons", err)
+        } else {
+            info.supportedThrottleReasons = mask
+            info.haveSupportedThrottleReasons = true
+        }
+    }
+
+    c.static[uuid] = info
+    return info
+}
+
+// minGraphicsClock returns the lowest graphics clock dev supports at any of
+// memClocks, or 0 if they can't be read.
+func minGraphicsClock(dev Device, memClocks []uint) uint {
+    r, ok := dev.(supportedGraphicsClocksReader)
+    if !ok {
+        return 0
+    }
+    var min uint
+    for _, memClock := range memClocks {
+        clocks, err := r.SupportedGraphicsClocks(memClock)
+        if err != nil {
+            logCallError("SupportedGraphicsClocks", err)
+            return 0
+        }
+        for _, clock := range clocks {
+            if min == 0 || clock < min {
+                min = clock
+            }
+        }
+    }
+    return min
+}
@@ -0,0 +1,1010 @@
+package collector
+
+import (
+    "fmt"
+    "math"
+    "strings"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// subCollector collects one group of per-device metrics. lv are the values
+// for the labels shared by all per-device metrics.
+type subCollector struct {
+    name    string
+    collect func(dev Device, lv []string) error
+}
+
+// subCollectors returns the enabled sub-collectors in collection order.
+func (c *Collector) subCollectors() []subCollector {
+    if *mode == "inventory" {
+        return []subCollector{{"inventory", c.collectInventory}}
+    }
+    scs := []subCollector{
+        {"memory", c.collectMemory},
+        {"utilization", c.collectUtilization},
+        {"power", c.collectPower},
+        {"temperature", c.collectTemperature},
+        {"throttling", c.collectThrottling},
+    }
+    if *enableFanSpeed {
+        scs = append(scs, subCollector{"fan", c.collectFan})
+    }
+    scs = append(scs,
+        subCollector{"video", c.collectVideo},
+        subCollector{"state", c.collectState},
+        subCollector{"clocks", c.collectClocks},
+        subCollector{"pcie", c.collectPCIe},
+        subCollector{"nvlink", c.collectNVLink},
+    )
+    if *enableClockPolicyMetrics {
+        scs = append(scs, subCollector{"clock_policy", c.collectClockPolicy})
+    }
+    if *enableClockLockDetection {
+        scs = append(scs, subCollector{"clock_lock", c.collectClockLock})
+    }
+    if *enableVirtualizationMetrics {
+        scs = append(scs, subCollector{"virtualization", c.collectVirtualization})
+    }
+    if *enableInforomMetrics {
+        scs = append(scs, subCollector{"inforom", c.collectInforom})
+    }
+    if *enableProcessMetrics {
+        scs = append(scs, subCollector{"processes", c.collectProcesses})
+    }
+    if *enableECCMetrics {
+        scs = append(scs, subCollector{"ecc", c.collectECC})
+    }
+    if *enableAERMetrics {
+        scs = append(scs, subCollector{"aer", c.collectAER})
+    }
+    if len(extraFields) > 0 {
+        scs = append(scs, subCollector{"fields", c.collectFields})
+    }
+    return scs
+}
+
+// optionalCollectors are the optional metric groups, in the bit order of
+// enabled_collectors_bitmap. Only ever append to keep the bits stable. Groups
+// that were removed keep their entry with a nil enabled, and their bit stays
+// 0.
+var optionalCollectors = []struct {
+    name    string
+    enabled *bool
+}{
+    {"fan", enableFanSpeed},
+    {"fan_failure_detection", enableFanFailureDetection},
+    {"power_limits", enablePowerLimits},
+    {"average_power", enableAveragePowerUsage},
+    {"clock_policy", enableClockPolicyMetrics},
+    {"preemption", nil},
+    {"engines", nil},
+    {"processes", enableProcessMetrics},
+    {"ecc", enableECCMetrics},
+    {"aer", enableAERMetrics},
+    {"memory_free", enableMemoryFreeMetrics},
+    {"clock_lock", enableClockLockDetection},
+    {"virtualization", enableVirtualizationMetrics},
+    {"inforom", enableInforomMetrics},
+}
+
+// enabledCollectorsBitmap returns the bitmap of the enabled
+// optionalCollectors and the help text documenting the bits.
+func enabledCollectorsBitmap() (bitmap float64, help string) {
+    bits := make([]string, len(optionalCollectors))
+    for i, oc := range optionalCollectors {
+        bit := 1 << uint(i)
+        if oc.enabled == nil {
+            bits[i] = fmt.Sprintf("%d=%s (removed)", bit, oc.name)
+            continue
+        }
+        bits[i] = fmt.Sprintf("%d=%s", bit, oc.name)
+        if *oc.enabled {
+            bitmap += float64(bit)
+        }
+    }
+    return bitmap, "Bitmap of the enabled optional metric groups: " + strings.Join(bits, ", ")
+}
+
+// memoryInfoV2Reader is implemented by devices reporting the memory reserved
+// by the driver separately from the used one (nvmlDeviceGetMemoryInfo_v2).
+type memoryInfoV2Reader interface {
+    MemoryInfoV2() (total, reserved, free, used uint64, err error)
+}
+
+// deviceReadings holds values read by one sub-collector that later ones
+// derive metrics from. It is reset before each device is collected.
+type deviceReadings struct {
+    throttleReasons     uint64 // mask of the active throttleReasons bits
+    haveThrottleReasons bool
+    temperature         uint
+    slowdownTemp        uint // 0 if the thresholds couldn't be read
+
+    // Enforced minus management power limit in milliwatts, from the power
+    // subcollector.
+    powerLimitDelta     int64
+    havePowerLimitDelta bool
+
+    uncorrectedECCErrors uint64 // volatile
+    haveECC              bool
+    remappingPending     bool
+    remappingFailed      bool
+    haveRemappedRows     bool
+    pcieDegraded         bool
+    havePCIeDegraded     bool
+
+    memoryUtilization     uint // percent
+    haveMemoryUtilization bool
+}
+
+// set sets the series of vec identified by lv and counts it towards the
+// device's collected metrics. With -collector.mark-removed or
+// -collector.zero-on-error it also remembers the series for the next scrape.
+func (c *Collector) set(vec *prometheus.GaugeVec, lv []string, value float64) {
+    vec.WithLabelValues(lv...).Set(value)
+    c.collected++
+    if *markRemovedDevices || *zeroOnError {
+        c.series = append(c.series, seriesRef{vec, lv, c.uuid})
+    }
+}
+
+// isNotSupported reports whether err only means the device or backend
+// doesn't have the reading.
+func isNotSupported(err error) bool {
+    return err == errNotSupported || strings.Contains(err.Error(), "Not Supported")
+}
+
+// firstError keeps the first real failure among a sub-collector's calls.
+type firstError struct {
+    err error
+}
+
+func (f *firstError) record(err error) {
+    if f.err == nil && err != nil && !isNotSupported(err) {
+        f.err = err
+    }
+}
+
+func (c *Collector) collectMemory(dev Device, lv []string) error {
+    var errs firstError
+
+    totalMemory, usedMemory, err := dev.MemoryInfo()
+    if err != nil {
+        c.callError("MemoryInfo", err)
+    } else {
+        c.set(c.usedMemory, lv, float64(usedMemory))
+        c.set(c.totalMemory, lv, float64(totalMemory))
+    }
+    errs.record(err)
+
+    if *enableMemoryFreeMetrics {
+        if r, ok := dev.(memoryInfoV2Reader); ok {
+            total, reserved, free, used, err := r.MemoryInfoV2()
+            if err == nil {
+                c.set(c.freeMemory, lv, float64(total)-float64(used)-float64(reserved))
+                if total > 0 {
+                    unaccounted := float64(total) - float64(reserved) - float64(used) - float64(free)
+                    c.set(c.memoryFragmentation, lv, math.Abs(unaccounted)/float64(total))
+                }
+            }
+            c.countError("MemoryInfoV2", err)
+            errs.record(err)
+        } else if err == nil {
+            // Without the v2 call the used memory includes the reserved one.
+            c.set(c.freeMemory, lv, float64(totalMemory)-float64(usedMemory))
+        }
+    }
+
+    totalBar1Memory, usedBar1Memory, err := dev.Bar1MemoryInfo()
+    if err != nil {
+        c.callError("Bar1MemoryInfo", err)
+    } else {
+        c.set(c.usedBar1Memory, lv, float64(usedBar1Memory))
+        c.set(c.totalBar1Memory, lv, float64(totalBar1Memory))
+    }
+    errs.record(err)
+
+    return errs.err
+}
+
+func (c *Collector) collectUtilization(dev Device, lv []string) error {
+    var errs firstError
+
+    utilizationGPU, utilizationMemory, err := dev.UtilizationRates()
+    if err == nil {
+        c.set(c.GPUUtilizationRate, lv, float64(utilizationGPU))
+        c.set(c.memoryUtilizationRate, lv, float64(utilizationMemory))
+        c.readings.memoryUtilization = utilizationMemory
+        c.readings.haveMemoryUtilization = true
+        if utilizationGPU > c.maxUtilization {
+            c.maxUtilization = utilizationGPU
+        }
+    }
+    c.countError("UtilizationRates", err)
+    errs.record(err)
+
+    for _, window := range averagingWindows {
+        utilizationGPUAverage, err := dev.AverageGPUUtilization(window)
+        if err == nil {
+            c.set(c.avgGPUUtilization, averagingWindowLabelValues(lv, window), float64(utilizationGPUAverage))
+        }
+        c.countError("AverageGPUUtilization", err)
+        errs.record(err)
+    }
+
+    if c.utilizationEMA != nil {
+        if uuid, err := dev.UUID(); err == nil {
+            if v, ok := c.utilizationEMA[uuid]; ok {
+                c.set(c.utilizationEMARatio, lv, v)
+            }
+        }
+    }
+
+    return errs.err
+}
+
+// powerUsageRatioMax caps power_usage_ratio, so a transient overshoot of the
+// limit doesn't skew alerts.
+const powerUsageRatioMax = 1.2
+
+func (c *Collector) collectPower(dev Device, lv []string) error {
+    var errs firstError
+
+    powerUsage, err := dev.PowerUsage()
+    havePowerUsage := err == nil
+    if err != nil {
+        c.callError("PowerUsage", err)
+    } else {
+        c.set(c.powerUsage, lv, powerValue(powerUsage))
+    }
+    errs.record(err)
+
+    if *enableAveragePowerUsage {
+        for _, window := range averagingWindows {
+            avgPowerUsage, err := dev.AveragePowerUsage(window)
+            if err != nil {
+                c.callError("AveragePowerUsage", err)
+            } else {
+                c.set(c.avgPowerUsage, averagingWindowLabelValues(lv, window), powerValue(avgPowerUsage))
+            }
+            errs.record(err)
+        }
+    }
+
+    energyConsumption, err := dev.TotalEnergyConsumption()
+    if err != nil {
+        c.callError("TotalEnergyConsumption", err)
+    } else {
+        c.set(c.energyConsumption, lv, energyValue(energyConsumption))
+    }
+    errs.record(err)
+
+    if *enablePowerLimits {
+        powerLimitConstraintsMin, powerLimitConstraintsMax, err := dev.PowerLimitConstraints()
+        if err != nil {
+            c.callError("PowerLimitConstraints", err)
+        } else {
+            c.set(c.powerLimitConstraintsMin, lv, powerValue(powerLimitConstraintsMin))
+            c.set(c.powerLimitConstraintsMax, lv, powerValue(powerLimitConstraintsMax))
+        }
+        errs.record(err)
+
+        powerLimitManagement, powerLimitEnforced, err := dev.PowerLimits()
+        if err != nil {
+            c.callError("PowerLimits", err)
+        } else {
+            c.set(c.powerLimitManagement, lv, powerValue(powerLimitManagement))
+            c.set(c.powerLimitEnforced, lv, powerValue(powerLimitEnforced))
+            c.set(c.powerLimitDelta, lv, powerValue(powerLimitEnforced)-powerValue(powerLimitManagement))
+            c.readings.powerLimitDelta = int64(powerLimitEnforced) - int64(powerLimitManagement)
+            c.readings.havePowerLimitDelta = true
+            if *expectedPowerLimit > 0 {
+                drift := math.Abs(float64(powerLimitManagement)/1000-*expectedPowerLimit) >= 1
+                c.set(c.powerLimitDrift, lv, boolToFloat(drift))
+            }
+            if havePowerUsage && powerLimitEnforced > 0 {
+                ratio := float64(powerUsage) / float64(powerLimitEnforced)
+                c.set(c.powerUsageRatio, lv, math.Min(ratio, powerUsageRatioMax))
+            }
+        }
+        errs.record(err)
+
+        powerManagementDefaultLimit, err := dev.PowerManagementDefaultLimit()
+        if err != nil {
+            c.callError("PowerManagementDefaultLimit", err)
+        } else {
+            c.set(c.powerManagementDefaultLimit, lv, powerValue(powerManagementDefaultLimit))
+            if havePowerUsage && powerManagementDefaultLimit > 0 {
+                c.set(c.tdpRatio, lv, float64(powerUsage)/float64(powerManagementDefaultLimit))
+            }
+        }
+        errs.record(err)
+    }
+
+    return errs.err
+}
+
+// temperatureSensor is a temperature reading besides the GPU core one, which
+// every device has. read returns errNotSupported when dev can't report it.
+type temperatureSensor struct {
+    name string
+    call string
+    read func(dev Device) (uint, error)
+}
+
+var temperatureSensors = []temperatureSensor{
+    {"memory", "MemoryTemperature", func(dev Device) (uint, error) {
+        r, ok := dev.(memoryTemperatureReader)
+        if !ok {
+            return 0, errNotSupported
+        }
+        return r.MemoryTemperature()
+    }},
+}
+
+// temperatureThresholdReader is implemented by devices reading any
+// nvmlTemperatureThresholds_t (nvmlDeviceGetTemperatureThreshold). Without it
+// only the shutdown and slowdown thresholds are known.
+type temperatureThresholdReader interface {
+    TemperatureThreshold(thresholdType uint) (uint, error)
+}
+
+var temperatureThresholdTypes = []struct {
+    name     string
+    nvmlType uint
+}{
+    {"shutdown", 0},
+    {"slowdown", 1},
+    {"mem_max", 2},
+    {"gpu_max", 3},
+    {"acoustic_min", 4},
+    {"acoustic_curr", 5},
+    {"acoustic_max", 6},
+}
+
+// temperatureSample is a GPU temperature reading kept for the next scrape.
+type temperatureSample struct {
+    celsius uint
+    at      time.Time
+}
+
+func (c *Collector) collectTemperature(dev Device, lv []string) error {
+    var errs firstError
+
+    temperature, temperatureErr := dev.Temperature()
+    if temperatureErr != nil {
+        c.callError("Temperature", temperatureErr)
+    } else {
+        c.set(c.temperature, append(lv, "gpu"), float64(temperature))
+        now := time.Now()
+        if prev, ok := c.prevTemperature[c.uuid]; ok {
+            if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+                c.set(c.temperatureChange, lv, (float64(temperature)-float64(prev.celsius))/elapsed)
+            }
+        }
+        c.prevTemperature[c.uuid] = temperatureSample{temperature, now}
+    }
+    errs.record(temperatureErr)
+
+    for _, sensor := range temperatureSensors {
+        v, err := sensor.read(dev)
+        if err == errNotSupported {
+            continue
+        }
+        if err != nil {
+            c.callError(sensor.call, err)
+        } else {
+            c.set(c.temperature, append(lv, sensor.name), float64(v))
+        }
+        errs.record(err)
+    }
+
+    temperature_threshold_shutdown, temperature_threshold_slowdown, err := dev.TemperatureThresholds()
+    if err != nil {
+        c.callError("TemperatureThresholds", err)
+    } else {
+        c.set(c.temperatureThresholdShutDown, lv, float64(temperature_threshold_shutdown))
+        c.set(c.temperatureThresholdSlowDown, lv, float64(temperature_threshold_slowdown))
+        if _, ok := dev.(temperatureThresholdReader); !ok {
+            c.set(c.temperatureThreshold, append(lv, "shutdown"), float64(temperature_threshold_shutdown))
+            c.set(c.temperatureThreshold, append(lv, "slowdown"), float64(temperature_threshold_slowdown))
+        }
+    }
+    errs.record(err)
+
+    if r, ok := dev.(temperatureThresholdReader); ok {
+        for _, t := range temperatureThresholdTypes {
+            v, err := r.TemperatureThreshold(t.nvmlType)
+            if err != nil {
+                if isNotSupported(err) {
+                    c.countError("TemperatureThreshold", err)
+                } else {
+                    c.callError("TemperatureThreshold", err)
+                }
+                errs.record(err)
+                continue
+            }
+            c.set(c.temperatureThreshold, append(lv, t.name), float64(v))
+        }
+    }
+
+    if temperatureErr == nil && err == nil {
+        c.readings.temperature = temperature
+        c.readings.slowdownTemp = temperature_threshold_slowdown
+    }
+
+    if temperatureErr == nil && err == nil && temperature_threshold_slowdown > 0 {
+        c.set(c.thermalHeadroom, lv, float64(temperature_threshold_slowdown) - float64(temperature))
+    }
+
+    return errs.err
+}
+
+func (c *Collector) collectThrottling(dev Device, lv []string) error {
+    var errs firstError
+
+    throttling_reason, err := dev.MostSeriousClocksThrottleReason()
+    if err != nil {
+        c.callError("throttlingReason", err)
+    } else {
+        c.set(c.throttlingReason, lv, float64(throttling_reason))
+    }
+    errs.record(err)
+
+    reasons, err := dev.CurrentClocksThrottleReasons()
+    if err != nil {
+        c.callError("CurrentClocksThrottleReasons", err)
+    } else {
+        c.readings.throttleReasons = reasons
+        c.readings.haveThrottleReasons = true
+        for _, r := range decodeThrottleReasons(reasons) {
+            c.throttleReasonScrapes.WithLabelValues(append(lv, r.name)...).Inc()
+        }
+        c.trackThermalThrottle(lv, reasons&thermalThrottleReasons != 0)
+    }
+    errs.record(err)
+
+    if *enablePowerLimits && c.readings.haveThrottleReasons && c.readings.havePowerLimitDelta {
+        c.set(c.powerLimitSource, lv, float64(powerLimitSource(c.readings.throttleReasons, c.readings.powerLimitDelta)))
+    }
+
+    return errs.err
+}
+
+// fanFailureMaxSpeed is the fan speed in percent up to which a fan on a GPU
+// hotter than its slowdown threshold is suspected dead.
+const fanFailureMaxSpeed = 5
+
+func (c *Collector) collectFan(dev Device, lv []string) error {
+    fanSpeed, err := dev.FanSpeed()
+    if err != nil {
+        c.callError("FanSpeed", err)
+    } else {
+        speed := float64(fanSpeed)
+        if *fanSmoothing > 0 {
+            if prev, ok := c.fanSpeedEMA[c.uuid]; ok {
+                speed = ema(prev, speed, *fanSmoothing)
+            }
+            c.fanSpeedEMA[c.uuid] = speed
+        }
+        c.set(c.fanSpeed, lv, speed)
+        if *enableFanFailureDetection && c.readings.slowdownTemp > 0 {
+            suspected := fanSpeed <= fanFailureMaxSpeed && c.readings.temperature > c.readings.slowdownTemp
+            c.set(c.fanFailureSuspected, lv, boolToFloat(suspected))
+        }
+    }
+
+    var errs firstError
+    errs.record(err)
+    return errs.err
+}
+
+func (c *Collector) collectVideo(dev Device, lv []string) error {
+    var errs firstError
+
+    encUsage, _, err := dev.EncoderUtilization()
+    if err != nil {
+        c.callError("EncoderUtilization", err)
+    } else {
+        c.set(c.encUsage, lv, float64(encUsage))
+    }
+    errs.record(err)
+
+    decUsage, _, err := dev.DecoderUtilization()
+    if err != nil {
+        c.callError("DecoderUtilization", err)
+    } else {
+        c.set(c.decUsage, lv, float64(decUsage))
+    }
+    errs.record(err)
+
+    if r, ok := dev.(jpegUtilizationReader); ok {
+        jpegUsage, _, err := r.JpgUtilization()
+        if err == nil {
+            c.set(c.jpegUsage, lv, float64(jpegUsage))
+        }
+        c.countError("JpgUtilization", err)
+        errs.record(err)
+    }
+    if r, ok := dev.(ofaUtilizationReader); ok {
+        ofaUsage, _, err := r.OfaUtilization()
+        if err == nil {
+            c.set(c.ofaUsage, lv, float64(ofaUsage))
+        }
+        c.countError("OfaUtilization", err)
+        errs.record(err)
+    }
+
+    caph264, caphevc, err := dev.EncoderCapacity()
+    if err == nil {
+        c.set(c.videoEncoderCapacityH264, lv, float64(caph264))
+        c.set(c.videoEncoderCapacityHEVC, lv, float64(caphevc))
+    }
+    c.countError("EncoderCapacity", err)
+    errs.record(err)
+
+    return errs.err
+}
+
+func (c *Collector) collectState(dev Device, lv []string) error {
+    var errs firstError
+
+    computeMode, err := dev.ComputeMode()
+    if err == nil {
+        c.set(c.computeMode, lv, float64(computeMode))
+    }
+    c.countError("ComputeMode", err)
+    errs.record(err)
+
+    performanceState, err := dev.PerformanceState()
+    if err == nil {
+        c.set(c.performanceState, lv, float64(performanceState))
+    }
+    c.countError("PerformanceState", err)
+    errs.record(err)
+
+    errs.record(c.collectInventory(dev, lv))
+    return errs.err
+}
+
+// collectInventory collects the device attributes that only change with
+// the configuration, which is all -mode=inventory collects.
+func (c *Collector) collectInventory(dev Device, lv []string) error {
+    var errs firstError
+
+    if r, ok := dev.(driverModelReader); ok {
+        current, pending, err := r.DriverModel()
+        if err == nil {
+            c.set(c.driverModelCurrent, lv, driverModelValue(current))
+            c.set(c.driverModelPending, lv, driverModelValue(pending))
+        }
+        c.countError("DriverModel", err)
+        errs.record(err)
+    }
+
+    if r, ok := dev.(migModeReader); ok {
+        current, pending, err := r.MigMode()
+        if err == nil {
+            c.set(c.migModeCurrent, lv, boolToFloat(current == nvmlMigEnable))
+            c.set(c.migModePending, lv, boolToFloat(pending == nvmlMigEnable))
+        }
+        c.countError("MigMode", err)
+        errs.record(err)
+    }
+
+    if info := c.staticInfo(dev, c.uuid); info.haveSupportedThrottleReasons {
+        for _, r := range throttleReasons {
+            c.set(c.throttleReasonSupported, append(lv, r.name), boolToFloat(info.supportedThrottleReasons&r.bit != 0))
+        }
+    }
+
+    return errs.err
+}
+
+// clockKey identifies one clock reading: its domain (graphics, sm, memory,
+// video) and whether it's the current or max speed.
+type clockKey struct {
+    clockType string
+    kind      string
+}
+
+// clockThrottledRatio is the fraction of its maximum below which a clock
+// counts as capped while a throttle reason is active.
+const clockThrottledRatio = 0.9
+
+// clockEntry is one clock collectClocks reads: its key, the device call
+// (for error reporting), and the metric it's exported as without
+// -metrics.unified-clocks.
+type clockEntry struct {
+    key  clockKey
+    call string
+    read func() (uint, error)
+    vec  *prometheus.GaugeVec
+}
+
+// customerBoostClockReader is implemented by devices reporting the customer
+// boost clock limit (nvmlDeviceGetMaxCustomerBoostClock).
+type customerBoostClockReader interface {
+    GrMaxCustomerBoostClock() (uint, error)
+}
+
+// requestedClockReader is implemented by devices reporting the graphics clock
+// they were asked to run at, i.e. the applications clock target
+// (nvmlDeviceGetClock with NVML_CLOCK_ID_APP_CLOCK_TARGET).
+type requestedClockReader interface {
+    GrRequestedClock() (uint, error)
+}
+
+// clockSample is a clock reading kept for the next scrape.
+type clockSample struct {
+    mhz uint
+    at  time.Time
+}
+
+func (c *Collector) collectClocks(dev Device, lv []string) error {
+    clocks := []clockEntry{
+        {clockKey{"graphics", "current"}, "GrClock", dev.GrClock, c.grClockCurrent},
+        {clockKey{"graphics", "max"}, "GrMaxClock", dev.GrMaxClock, c.grClockMax},
+        {clockKey{"sm", "current"}, "SMClock", dev.SMClock, c.SMClockCurrent},
+        {clockKey{"sm", "max"}, "SMMaxClock", dev.SMMaxClock, c.SMClockMax},
+        {clockKey{"memory", "current"}, "MemClock", dev.MemClock, c.memClockCurrent},
+        {clockKey{"memory", "max"}, "MemMaxClock", dev.MemMaxClock, c.memClockMax},
+        {clockKey{"video", "current"}, "VideoClock", dev.VideoClock, c.videoClockCurrent},
+        {clockKey{"video", "max"}, "VideoMaxClock", dev.VideoMaxClock, c.videoClockMax},
+    }
+    if r, ok := dev.(customerBoostClockReader); ok {
+        clocks = append(clocks, clockEntry{clockKey{"graphics", "customer_max_boost"}, "GrMaxCustomerBoostClock", r.GrMaxCustomerBoostClock, c.grClockCustomerMaxBoost})
+    }
+
+    var errs firstError
+    readings := make(map[clockKey]uint)
+    for _, clock := range clocks {
+        v, err := clock.read()
+        c.countError(clock.call, err)
+        errs.record(err)
+        if err != nil {
+            continue
+        }
+        readings[clock.key] = v
+        if *unifiedClocks {
+            c.set(c.clock, append(lv, clock.key.clockType, clock.key.kind), float64(v))
+        } else {
+            c.set(clock.vec, lv, float64(v))
+        }
+    }
+
+    // Only worth exporting next to the clock actually achieved.
+    if r, ok := dev.(requestedClockReader); ok {
+        if _, ok := readings[clockKey{"graphics", "current"}]; ok {
+            v, err := r.GrRequestedClock()
+            c.countError("GrRequestedClock", err)
+            errs.record(err)
+            if err == nil {
+                if *unifiedClocks {
+                    c.set(c.clock, append(lv, "graphics", "requested"), float64(v))
+                } else {
+                    c.set(c.grClockRequested, lv, float64(v))
+                }
+            }
+        }
+    }
+
+    if sm, ok := readings[clockKey{"sm", "current"}]; ok {
+        if gr, ok := readings[clockKey{"graphics", "current"}]; ok {
+            c.set(c.smGrClockDelta, lv, float64(sm)-float64(gr))
+        }
+    }
+
+    info := c.staticInfo(dev, c.uuid)
+    for _, min := range []struct {
+        clockType string
+        mhz       uint
+        vec       *prometheus.GaugeVec
+    }{
+        {"graphics", info.minGrClock, c.grClockMin},
+        {"memory", info.minMemClock, c.memClockMin},
+    } {
+        if min.mhz == 0 {
+            continue
+        }
+        if *unifiedClocks {
+            c.set(c.clock, append(lv, min.clockType, "min"), float64(min.mhz))
+        } else {
+            c.set(min.vec, lv, float64(min.mhz))
+        }
+    }
+
+    // The max graphics clock is the rated boost clock; 0 means unknown.
+    if current, ok := readings[clockKey{"graphics", "current"}]; ok {
+        if boost := readings[clockKey{"graphics", "max"}]; boost > 0 {
+            c.set(c.atBoostClock, lv, boolToFloat(current >= boost))
+        }
+    }
+
+    if current, ok := readings[clockKey{"graphics", "current"}]; ok {
+        now := time.Now()
+        if prev, ok := c.prevGrClock[c.uuid]; ok {
+            if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+                c.set(c.grClockChange, lv, (float64(current)-float64(prev.mhz))/elapsed)
+            }
+        }
+        c.prevGrClock[c.uuid] = clockSample{current, now}
+    }
+
+    if current, ok := readings[clockKey{"memory", "current"}]; ok && c.readings.haveMemoryUtilization {
+        c.set(c.memClockUtilizationProduct, lv, float64(current)*float64(c.readings.memoryUtilization)/100)
+    }
+
+    if current, ok := readings[clockKey{"memory", "current"}]; ok {
+        if min := info.minMemClock; min > 0 {
+            c.set(c.memClockIdle, lv, boolToFloat(current <= min))
+        }
+    }
+
+    if c.readings.haveThrottleReasons {
+        throttled := c.readings.throttleReasons&^throttleReasonGpuIdle != 0
+        for clockType, vec := range map[string]*prometheus.GaugeVec{
+            "graphics": c.grClockThrottled,
+            "memory":   c.memClockThrottled,
+            "video":    c.videoClockThrottled,
+        } {
+            current, ok := readings[clockKey{clockType, "current"}]
+            max, maxOk := readings[clockKey{clockType, "max"}]
+            if ok && maxOk && max > 0 {
+                c.set(vec, lv, boolToFloat(throttled && float64(current) < clockThrottledRatio*float64(max)))
+            }
+        }
+    }
+
+    return errs.err
+}
+
+// pcieByteCountersReader is implemented by devices with cumulative PCIe byte
+// counters (NVML_FI_DEV_PCIE_COUNT_TX_BYTES and _RX_BYTES).
+type pcieByteCountersReader interface {
+    PcieByteCounters() (tx uint64, rx uint64, err error)
+}
+
+// pcieCounterSample is a PCIe byte counters reading kept for the next
+// scrape.
+type pcieCounterSample struct {
+    tx, rx uint64
+    at     time.Time
+}
+
+// collectPCIeCounted exports the PCIe throughput averaged since the previous
+// scrape from the byte counters, for -pcie.mode=counted. The first scrape of
+// a device only records the counters. It returns the error of a failed read,
+// even one meaning the counters aren't supported, so collectPCIe can fall
+// back to the instant reading.
+func (c *Collector) collectPCIeCounted(r pcieByteCountersReader, lv []string) error {
+    tx, rx, err := r.PcieByteCounters()
+    c.countError("PcieByteCounters", err)
+    if err != nil {
+        return err
+    }
+
+    now := time.Now()
+    prev, ok := c.prevPCIeCounters[c.uuid]
+    c.prevPCIeCounters[c.uuid] = pcieCounterSample{tx, rx, now}
+    elapsed := now.Sub(prev.at).Seconds()
+    // Counters going backwards were reset, e.g. by a driver reload.
+    if !ok || elapsed <= 0 || tx < prev.tx || rx < prev.rx {
+        return nil
+    }
+    c.set(c.pciTxThroughput, lv, float64(tx-prev.tx)/1024/elapsed)
+    c.set(c.pciRxThroughput, lv, float64(rx-prev.rx)/1024/elapsed)
+    return nil
+}
+
+func (c *Collector) collectPCIe(dev Device, lv []string) error {
+    var errs firstError
+
+    // Drivers older than the byte counter fields fail the counted read;
+    // the instant reading stands in for it.
+    r, counted := dev.(pcieByteCountersReader)
+    if !counted || *pcieMode != "counted" || c.collectPCIeCounted(r, lv) != nil {
+        pciTxThroughput, err := dev.PcieTxThroughput()
+        if err == nil {
+            c.set(c.pciTxThroughput, lv, float64(pciTxThroughput))
+        }
+        c.countError("PcieTxThroughput", err)
+        errs.record(err)
+        PciRxThroughput, err := dev.PcieRxThroughput()
+        if err == nil {
+            c.set(c.pciRxThroughput, lv, float64(PciRxThroughput))
+        }
+        c.countError("PcieRxThroughput", err)
+        errs.record(err)
+    }
+    pciLinkGenerationCurrent, genCurrentErr := dev.PcieGeneration()
+    if genCurrentErr == nil {
+        c.set(c.pciLinkGenerationCurrent, lv, float64(pciLinkGenerationCurrent))
+    }
+    c.countError("PcieGeneration", genCurrentErr)
+    errs.record(genCurrentErr)
+    pciLinkGenerationMax, genMaxErr := dev.PcieMaxGeneration()
+    if genMaxErr == nil {
+        c.set(c.pciLinkGenerationMax, lv, float64(pciLinkGenerationMax))
+    }
+    c.countError("PcieMaxGeneration", genMaxErr)
+    errs.record(genMaxErr)
+    pciLinkWidthCurrent, widthCurrentErr := dev.PcieWidth()
+    if widthCurrentErr == nil {
+        c.set(c.pciLinkWidthCurrent, lv, float64(pciLinkWidthCurrent))
+    }
+    c.countError("PcieWidth", widthCurrentErr)
+    errs.record(widthCurrentErr)
+    pciLinkWidthMax, widthMaxErr := dev.PcieMaxWidth()
+    if widthMaxErr == nil {
+        c.set(c.pciLinkWidthMax, lv, float64(pciLinkWidthMax))
+    }
+    c.countError("PcieMaxWidth", widthMaxErr)
+    errs.record(widthMaxErr)
+
+    haveGen := genCurrentErr == nil && genMaxErr == nil
+    haveWidth := widthCurrentErr == nil && widthMaxErr == nil
+    if haveGen || haveWidth {
+        degraded := (haveGen && pciLinkGenerationCurrent < pciLinkGenerationMax) ||
+            (haveWidth && pciLinkWidthCurrent < pciLinkWidthMax)
+        c.set(c.pciLinkDegraded, lv, boolToFloat(degraded))
+        c.readings.pcieDegraded = degraded
+        c.readings.havePCIeDegraded = true
+    }
+
+    return errs.err
+}
+
+func (c *Collector) collectClockPolicy(dev Device, lv []string) error {
+    abr, ok := dev.(autoBoostReader)
+    if !ok {
+        return nil
+    }
+    enabled, defaultEnabled, err := abr.AutoBoostedClocksEnabled()
+    if err == nil {
+        c.set(c.autoBoostEnabled, lv, boolToFloat(enabled))
+        c.set(c.autoBoostDefaultEnabled, lv, boolToFloat(defaultEnabled))
+    }
+
+    var errs firstError
+    c.countError("AutoBoostedClocksEnabled", err)
+    errs.record(err)
+    return errs.err
+}
+
+// applicationsClocksReader is implemented by devices reporting their
+// applications clocks and the default ones in MHz
+// (nvmlDeviceGetApplicationsClock, nvmlDeviceGetDefaultApplicationsClock).
+type applicationsClocksReader interface {
+    ApplicationsClocks() (graphics uint, memory uint, err error)
+    DefaultApplicationsClocks() (graphics uint, memory uint, err error)
+}
+
+func (c *Collector) collectClockLock(dev Device, lv []string) error {
+    r, ok := dev.(applicationsClocksReader)
+    if !ok {
+        return nil
+    }
+    var errs firstError
+    graphics, memory, err := r.ApplicationsClocks()
+    c.countError("ApplicationsClocks", err)
+    errs.record(err)
+    defaultGraphics, defaultMemory, defaultErr := r.DefaultApplicationsClocks()
+    c.countError("DefaultApplicationsClocks", defaultErr)
+    errs.record(defaultErr)
+
+    if err == nil && defaultErr == nil {
+        c.set(c.clocksLockedByUser, lv, boolToFloat(graphics != defaultGraphics || memory != defaultMemory))
+    }
+    return errs.err
+}
+
+// virtualizationModeReader is implemented by devices reporting their
+// virtualization mode (nvmlDeviceGetVirtualizationMode).
+type virtualizationModeReader interface {
+    VirtualizationMode() (uint, error)
+}
+
+// virtualizationModes are the names of NVML's virtualization modes
+// (NVML_GPU_VIRTUALIZATION_MODE_*), by value.
+var virtualizationModes = []string{"none", "pass-through", "vgpu", "host-vgpu", "host-vsga"}
+
+func (c *Collector) collectVirtualization(dev Device, lv []string) error {
+    r, ok := dev.(virtualizationModeReader)
+    if !ok {
+        return nil
+    }
+    mode, err := r.VirtualizationMode()
+    if err == nil {
+        c.set(c.virtualizationMode, lv, float64(mode))
+        if mode < uint(len(virtualizationModes)) {
+            c.set(c.virtualizationModeInfo, append(lv, virtualizationModes[mode]), 1)
+        }
+    }
+
+    var errs firstError
+    c.countError("VirtualizationMode", err)
+    errs.record(err)
+    return errs.err
+}
+
+// inforomReader is implemented by devices checking their inforom
+// (nvmlDeviceValidateInforom, with NVML_ERROR_CORRUPTED_INFOROM as false)
+// and reporting the version of its objects (nvmlDeviceGetInforomVersion).
+type inforomReader interface {
+    InforomValid() (bool, error)
+    InforomVersion(object uint) (string, error)
+}
+
+// inforomObjects are the names of NVML's inforom objects
+// (NVML_INFOROM_*), by value.
+var inforomObjects = []string{"oem", "ecc", "power"}
+
+func (c *Collector) collectInforom(dev Device, lv []string) error {
+    r, ok := dev.(inforomReader)
+    if !ok {
+        return nil
+    }
+    var errs firstError
+
+    valid, err := r.InforomValid()
+    if err == nil {
+        c.set(c.inforomValid, lv, boolToFloat(valid))
+    }
+    c.countError("InforomValid", err)
+    errs.record(err)
+
+    for object, name := range inforomObjects {
+        version, err := r.InforomVersion(uint(object))
+        if err == nil {
+            c.set(c.inforomVersion, append(lv, name, version), 1)
+        }
+        c.countError("InforomVersion", err)
+        errs.record(err)
+    }
+    return errs.err
+}
+
+func (c *Collector) collectECC(dev Device, lv []string) error {
+    var errs firstError
+
+    if r, ok := dev.(eccErrorReader); ok {
+        correctedVolatile, correctedAggregate, uncorrectedVolatile, uncorrectedAggregate, err := r.TotalEccErrors()
+        if err == nil {
+            c.set(c.eccErrors, append(lv, "corrected", "volatile"), float64(correctedVolatile))
+            c.set(c.eccErrors, append(lv, "uncorrected", "volatile"), float64(uncorrectedVolatile))
+            if *eccIncludeAggregate {
+                c.set(c.eccErrors, append(lv, "corrected", "aggregate"), float64(correctedAggregate))
+                c.set(c.eccErrors, append(lv, "uncorrected", "aggregate"), float64(uncorrectedAggregate))
+            }
+            c.readings.uncorrectedECCErrors = uncorrectedVolatile
+            c.readings.haveECC = true
+        }
+        c.countError("TotalEccErrors", err)
+        errs.record(err)
+    }
+
+    // Only feeds health_score.
+    if r, ok := dev.(remappedRowsReader); ok {
+        _, _, pending, failed, err := r.RemappedRows()
+        if err == nil {
+            c.readings.remappingPending = pending
+            c.readings.remappingFailed = failed
+            c.readings.haveRemappedRows = true
+        }
+        c.countError("RemappedRows", err)
+        errs.record(err)
+    }
+
+    if r, ok := dev.(sramECCThresholdReader); ok {
+        exceeded, err := r.SramEccErrorThresholdExceeded()
+        if err == nil {
+            c.set(c.sramECCThresholdExceeded, lv, boolToFloat(exceeded))
+        }
+        c.countError("SramEccErrorThresholdExceeded", err)
+        errs.record(err)
+    }
+
+    return errs.err
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// generationProvider starts a new read generation on every scrape. Its
+// device's readings depend on the generation, so values read in different
+// passes don't fit together.
+type generationProvider struct {
+    gen *uint
+}
+
+func (p generationProvider) DeviceCount() (uint, error) {
+    *p.gen++
+    return 1, nil
+}
+
+func (p generationProvider) DeviceHandleByIndex(index uint) (Device, error) {
+    if index != 0 {
+        return nil, fmt.Errorf("no device with index %d", index)
+    }
+    return generationDevice{gen: p.gen}, nil
+}
+
+// generationDevice reads 10% memory utilization and 1000 MHz memory clock per
+// generation, and fails to read the utilization in the third.
+type generationDevice struct {
+    mockDevice
+    gen *uint
+}
+
+func (d generationDevice) UtilizationRates() (uint, uint, error) {
+    if *d.gen == 3 {
+        return 0, 0, errors.New("NVML: Timeout")
+    }
+    return 50, 10 * *d.gen, nil
+}
+
+func (d generationDevice) MemClock() (uint, error) { return 1000 * *d.gen, nil }
+
+// gaugeValue returns the value of the single series of the gauge name, and
+// whether there is one.
+func gaugeValue(mfs map[string]*dto.MetricFamily, name string) (float64, bool) {
+    mf := mfs[name]
+    if len(mf.GetMetric()) != 1 {
+        return 0, false
+    }
+    return mf.GetMetric()[0].GetGauge().GetValue(), true
+}
+
+func TestMemoryClockUtilizationProductFromOneGeneration(t *testing.T) {
+    var gen uint
+    reg := prometheus.NewPedanticRegistry()
+    if err := reg.Register(NewCollector(generationProvider{&gen})); err != nil {
+        t.Fatal(err)
+    }
+
+    for scrape := 1; scrape <= 4; scrape++ {
+        mfs := gatherByName(t, reg)
+        product, haveProduct := gaugeValue(mfs, namespace+"_memory_clock_utilization_product")
+        utilization, haveUtilization := gaugeValue(mfs, namespace+"_memory_utilization_rate")
+        clock, haveClock := gaugeValue(mfs, namespace+"_clock_mem_current_mhz")
+
+        if !haveClock || clock != float64(1000*gen) {
+            t.Fatalf("scrape %d: clock_mem_current_mhz = %v, want %v of this generation", scrape, clock, 1000*gen)
+        }
+        if gen == 3 {
+            // The utilization of the previous pass must not be combined
+            // with this pass's clock.
+            if haveProduct {
+                t.Errorf("scrape %d: memory_clock_utilization_product = %v without a utilization reading", scrape, product)
+            }
+            continue
+        }
+        if !haveUtilization || utilization != float64(10*gen) {
+            t.Fatalf("scrape %d: memory_utilization_rate = %v, want %v of this generation", scrape, utilization, 10*gen)
+        }
+        if want := clock * utilization / 100; !haveProduct || product != want {
+            t.Errorf("scrape %d: memory_clock_utilization_product = %v, want %v from this generation's readings", scrape, product, want)
+        }
+    }
+}
+
+// oldDriverPCIeDevice has byte counters that fail like they do on drivers
+// without the NVML_FI_DEV_PCIE_COUNT_* fields.
+type oldDriverPCIeDevice struct {
+    mockDevice
+}
+
+func (oldDriverPCIeDevice) PcieByteCounters() (uint64, uint64, error) {
+    return 0, 0, errors.New("NVML: Not Supported")
+}
+
+func TestPCIeCountedFallsBackToInstant(t *testing.T) {
+    defer func(mode string) { *pcieMode = mode }(*pcieMode)
+    *pcieMode = "counted"
+
+    reg := prometheus.NewPedanticRegistry()
+    if err := reg.Register(NewCollector(listProvider{oldDriverPCIeDevice{}})); err != nil {
+        t.Fatal(err)
+    }
+    mfs := gatherByName(t, reg)
+    if tx, ok := gaugeValue(mfs, namespace+"_pci_throughput_tx_kilobytes_per_second"); !ok || tx != 1000 {
+        t.Errorf("pci_throughput_tx_kilobytes_per_second = %v, want the instant reading 1000", tx)
+    }
+    if rx, ok := gaugeValue(mfs, namespace+"_pci_throughput_rx_kilobytes_per_second"); !ok || rx != 2000 {
+        t.Errorf("pci_throughput_rx_kilobytes_per_second = %v, want the instant reading 2000", rx)
+    }
+}
+
+func TestHealthScoreRemapping(t *testing.T) {
+    for _, tc := range []struct {
+        readings deviceReadings
+        want     float64
+    }{
+        {deviceReadings{haveRemappedRows: true}, 100},
+        {deviceReadings{haveRemappedRows: true, remappingPending: true}, 50},
+        {deviceReadings{haveRemappedRows: true, remappingPending: true, remappingFailed: true}, 0},
+    } {
+        if got, ok := healthScore(tc.readings); !ok || got != tc.want {
+            t.Errorf("healthScore(%+v) = %v, %v, want %v", tc.readings, got, ok, tc.want)
+        }
+    }
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+    "log"
+    "sync"
+    "time"
+
+    "github.com/coreos/go-systemd/v22/daemon"
+)
+
+// systemdWatchdog is set at startup when systemd expects watchdog pings,
+// i.e. the unit has WatchdogSec= set.
+var systemdWatchdog bool
+
+// notifySystemdReady tells systemd the exporter is serving and returns the
+// watchdog timeout, 0 if the watchdog is off. Both are no-ops when not
+// running under systemd.
+func notifySystemdReady() time.Duration {
+    if _, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+        log.Printf("Couldn't notify systemd: %v", err)
+    }
+
+    timeout, err := daemon.SdWatchdogEnabled(false)
+    if err != nil {
+        log.Printf("Couldn't read systemd watchdog settings: %v", err)
+        return 0
+    }
+    if timeout > 0 {
+        systemdWatchdog = true
+        log.Printf("systemd watchdog enabled with a %v timeout, pinging it after every sweep and every %v unless a sweep is stuck", timeout, timeout/2)
+    }
+    return timeout
+}
+
+// pingSystemdWatchdog tells systemd collection isn't wedged, so it only
+// restarts the exporter when a sweep hangs.
+func pingSystemdWatchdog() {
+    if !systemdWatchdog {
+        return
+    }
+    if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+        log.Printf("Couldn't ping systemd watchdog: %v", err)
+    }
+}
+
+// sweepWatch tracks the sweep in progress for the watchdog. It has its own
+// lock as a stuck sweep holds the collector's.
+type sweepWatch struct {
+    sync.Mutex
+    started time.Time
+}
+
+func (w *sweepWatch) start() {
+    w.Lock()
+    defer w.Unlock()
+    w.started = time.Now()
+}
+
+// finish pings the watchdog after a sweep, whatever errors the devices
+// returned: only a sweep that doesn't finish is a wedged collection.
+func (w *sweepWatch) finish() {
+    w.Lock()
+    w.started = time.Time{}
+    w.Unlock()
+    pingSystemdWatchdog()
+}
+
+// runningFor returns how long the sweep in progress has been running, 0
+// between sweeps.
+func (w *sweepWatch) runningFor(now time.Time) time.Duration {
+    w.Lock()
+    defer w.Unlock()
+    if w.started.IsZero() {
+        return 0
+    }
+    return now.Sub(w.started)
+}
+
+// startWatchdogTicker pings the watchdog every half timeout, so quiet spells
+// between scrapes don't get the exporter restarted, unless a sweep has been
+// running for that long. A sweep that hangs thus gets the exporter
+// restarted about timeout after it started. The returned function stops
+// the ticker and waits for it to exit.
+func (c *Collector) startWatchdogTicker(timeout time.Duration) (stop func()) {
+    quit := make(chan struct{})
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(timeout / 2)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-quit:
+                return
+            case now := <-ticker.C:
+                if c.watch.runningFor(now) < timeout/2 {
+                    pingSystemdWatchdog()
+                }
+            }
+        }
+    }()
+
+    return func() {
+        close(quit)
+        <-done
+    }
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// throttleReasonGpuIdle is set when the clocks are lowered because the GPU
+// has nothing to do, which isn't throttling in any meaningful sense.
+const throttleReasonGpuIdle = 0x1
+
+// supportedThrottleReasonsReader is implemented by devices reporting which
+// throttle reasons they can detect at all
+// (nvmlDeviceGetSupportedClocksThrottleReasons).
+type supportedThrottleReasonsReader interface {
+    SupportedClocksThrottleReasons() (uint64, error)
+}
+
+// throttleReason is one bit of NVML's clocks throttle reasons mask
+// (nvmlClocksThrottleReason*), as returned by CurrentClocksThrottleReasons.
+// MostSeriousClocksThrottleReason returns gonvml's ThrottlingReason enum
+// instead, which doesn't map onto these bits.
+type throttleReason struct {
+    bit         uint64
+    name        string
+    description string
+}
+
+var throttleReasons = []throttleReason{
+    {throttleReasonGpuIdle, "gpu_idle", "GPU is idle and clocks are lowered to save power"},
+    {0x2, "applications_clocks_setting", "Clocks are limited by the applications clocks setting"},
+    {0x4, "sw_power_cap", "Clocks are lowered to stay under the power limit"},
+    {0x8, "hw_slowdown", "Hardware slowdown: the GPU is too hot, the power brake is engaged or the power supply is inadequate"},
+    {0x10, "sync_boost", "Clocks are synchronized with other GPUs in a sync boost group"},
+    {0x20, "sw_thermal_slowdown", "Clocks are lowered to keep the GPU under its maximum operating temperature"},
+    {0x40, "hw_thermal_slowdown", "Hardware thermal slowdown: the GPU or memory is too hot"},
+    {0x80, "hw_power_brake_slowdown", "Hardware power brake slowdown asserted by the system"},
+    {0x100, "display_clock_setting", "Clocks are limited by the display clock setting"},
+}
+
+// thermalThrottleReasons are the throttle reasons caused by heat:
+// sw_thermal_slowdown and hw_thermal_slowdown.
+const thermalThrottleReasons = 0x20 | 0x40
+
+// limiterThrottleReasons are the throttle reasons that hold a busy GPU back:
+// sw_power_cap, hw_slowdown, the thermal ones and hw_power_brake_slowdown.
+// Idle, sync boost and the clock settings are configured behaviour.
+const limiterThrottleReasons = 0x4 | 0x8 | thermalThrottleReasons | 0x80
+
+// throttleSample is a device's thermal throttling state kept for the next
+// scrape.
+type throttleSample struct {
+    throttled bool
+    at        time.Time
+}
+
+// trackThermalThrottle counts the onsets of thermal throttling and the time
+// spent throttled. Throttling is only sampled at scrapes, so the time since
+// the previous scrape counts as throttled if the device was throttled then.
+// Must be called with the collector locked.
+func (c *Collector) trackThermalThrottle(lv []string, throttled bool) {
+    now := time.Now()
+    seconds := c.thermalThrottleSeconds.WithLabelValues(lv...)
+    events := c.thermalThrottleEvents.WithLabelValues(lv...)
+    prev, ok := c.prevThrottle[c.uuid]
+    if ok && prev.throttled {
+        seconds.Add(now.Sub(prev.at).Seconds())
+    }
+    if throttled && (!ok || !prev.throttled) {
+        events.Inc()
+    }
+    c.prevThrottle[c.uuid] = throttleSample{throttled, now}
+}
+
+// Values of the power_limit_source metric.
+const (
+    powerLimitSourceNone = iota
+    powerLimitSourceSW
+    powerLimitSourceHWSlowdown
+    powerLimitSourceHWBrake
+    powerLimitSourceOOB
+)
+
+// powerLimitSource tells which limiter holds the GPU below its power limit,
+// from the active throttle reasons mask (hw_power_brake_slowdown,
+// hw_slowdown and sw_power_cap) and the enforced minus management power
+// limit. The hardware ones win since they cut deepest; an enforced limit
+// below the management one that nothing on the GPU explains was set out of
+// band, e.g. by the BMC.
+func powerLimitSource(mask uint64, limitDelta int64) int {
+    switch {
+    case mask&0x80 != 0:
+        return powerLimitSourceHWBrake
+    case mask&0x8 != 0:
+        return powerLimitSourceHWSlowdown
+    case limitDelta < 0:
+        return powerLimitSourceOOB
+    case mask&0x4 != 0:
+        return powerLimitSourceSW
+    }
+    return powerLimitSourceNone
+}
+
+// decodeThrottleReasons returns the reasons set in mask.
+func decodeThrottleReasons(mask uint64) []throttleReason {
+    var active []throttleReason
+    for _, r := range throttleReasons {
+        if mask&r.bit != 0 {
+            active = append(active, r)
+        }
+    }
+    return active
+}
+
+// clocksStatusHandler serves a plain text summary of why each device's
+// clocks are currently throttled, for reading during incidents.
+func clocksStatusHandler(c *Collector) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        if _, err := io.WriteString(w, c.clocksStatus()); err != nil {
+            log.Printf("Writing /clocks-status response: %v", err)
+        }
+    })
+}
+
+func (c *Collector) clocksStatus() string {
+    // Don't interleave with a scrape.
+    c.Lock()
+    defer c.Unlock()
+
+    var b strings.Builder
+    numDevices, err := c.provider.DeviceCount()
+    if err != nil {
+        fmt.Fprintf(&b, "DeviceCount() error: %v\n", err)
+        return b.String()
+    }
+    // The same devices in the same order as the metrics.
+    devices, complete := c.deviceHandles(numDevices, false)
+    if !complete {
+        b.WriteString("Some devices couldn't be opened, see the log\n\n")
+    }
+    for _, d := range devices {
+        i, dev := d.index, d.dev
+        name, _ := dev.Name()
+        uuid, _ := dev.UUID()
+        fmt.Fprintf(&b, "GPU %d: %s (%s)\n", i, name, uuid)
+
+        mask, err := dev.CurrentClocksThrottleReasons()
+        switch {
+        case err != nil:
+            fmt.Fprintf(&b, "  throttle reasons unavailable: %v\n", err)
+        case mask == 0:
+            fmt.Fprintf(&b, "  not throttled\n")
+        default:
+            for _, r := range decodeThrottleReasons(mask) {
+                fmt.Fprintf(&b, "  %s: %s\n", r.name, r.description)
+            }
+        }
+        b.WriteString("\n")
+    }
+    return b.String()
+}
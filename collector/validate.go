@@ -0,0 +1,154 @@
+package collector
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// validateExempt are metrics that legitimately stay absent for the mock
+// device, with the reason.
+var validateExempt = map[string]string{
+    namespace + "_clock_mhz":                    "replaces the per-clock metrics only with -metrics.unified-clocks",
+    namespace + "_pcie_aer_correctable_total":   "read from the host's sysfs",
+    namespace + "_pcie_aer_uncorrectable_total": "read from the host's sysfs",
+    namespace + "_collection_error":             "only counts failed calls",
+}
+
+// validateFlags turn on every optional group of metrics for -validate-metrics.
+var validateFlags = []string{
+    "label.vbios",
+    "enable-process-metrics",
+    "enable-ecc-metrics",
+    "enable-clock-policy-metrics",
+    "enable-fan-failure-detection",
+    "enable-aer-metrics",
+    "enable-memory-free-metrics",
+    "enable-clock-lock-detection",
+    "enable-virtualization-metrics",
+    "enable-inforom-metrics",
+}
+
+// validateSettings are the values of the other flags -validate-metrics needs
+// to collect every metric.
+var validateSettings = map[string]string{
+    "nvml.extra-fields":          "1",
+    "power.expected-limit-watts": "250",
+}
+
+var descNamePattern = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// validateMetrics checks that every metric the collector describes is also
+// collected, for a mock device that supports every reading, and that the
+// registry accepts what is collected. It prints the problems found and
+// returns the process exit code.
+func validateMetrics() int {
+    if err := setValidateFlags(); err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return 1
+    }
+
+    c, mfs, err := gatherMock()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return 1
+    }
+
+    missing, err := missingMetrics(c, mfs)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return 1
+    }
+    if len(missing) > 0 {
+        for _, name := range missing {
+            fmt.Fprintf(os.Stderr, "%s is described but never collected\n", name)
+        }
+        return 1
+    }
+    fmt.Println("All described metrics are collected")
+    return 0
+}
+
+// missingMetrics returns the sorted names of the metrics c describes that
+// aren't in the gathered mfs and aren't exempt.
+func missingMetrics(c prometheus.Collector, mfs map[string]*dto.MetricFamily) ([]string, error) {
+    var missing []string
+    for _, desc := range describe(c) {
+        m := descNamePattern.FindStringSubmatch(desc.String())
+        if m == nil {
+            return nil, fmt.Errorf("Can't parse %s", desc)
+        }
+        if mfs[m[1]] == nil && validateExempt[m[1]] == "" {
+            missing = append(missing, m[1])
+        }
+    }
+    sort.Strings(missing)
+    return missing, nil
+}
+
+// setValidateFlags sets validateFlags and validateSettings, and parses the
+// flag values main parses before collecting.
+func setValidateFlags() error {
+    for _, name := range validateFlags {
+        if err := flags.Set(name, "true"); err != nil {
+            return fmt.Errorf("Setting -%s: %v", name, err)
+        }
+    }
+    for name, value := range validateSettings {
+        if err := flags.Set(name, value); err != nil {
+            return fmt.Errorf("Setting -%s: %v", name, err)
+        }
+    }
+    var err error
+    if averagingWindows, err = parseAveragingWindows(*averageDurations, *averageDuration); err != nil {
+        return err
+    }
+    extraFields, err = parseFieldIDs(*nvmlExtraFields)
+    return err
+}
+
+// gatherMock registers a new collector for mockProvider with a pedantic
+// registry and returns it together with the metric families collected by
+// two scrapes, by name.
+func gatherMock() (*Collector, map[string]*dto.MetricFamily, error) {
+    c := NewCollector(mockProvider{})
+    c.utilizationEMA = make(map[string]float64)
+    c.sampleUtilization(1)
+    reg := prometheus.NewPedanticRegistry()
+    if err := reg.Register(c); err != nil {
+        return nil, nil, fmt.Errorf("Registering the collector: %v", err)
+    }
+
+    // Some metrics are derived from the previous scrape.
+    collected := make(map[string]*dto.MetricFamily)
+    for i := 0; i < 2; i++ {
+        mfs, err := reg.Gather()
+        if err != nil {
+            return nil, nil, fmt.Errorf("Gathering metrics: %v", err)
+        }
+        for _, mf := range mfs {
+            collected[mf.GetName()] = mf
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    return c, collected, nil
+}
+
+// describe returns the descriptors of c's metrics.
+func describe(c prometheus.Collector) []*prometheus.Desc {
+    ch := make(chan *prometheus.Desc)
+    go func() {
+        c.Describe(ch)
+        close(ch)
+    }()
+    var descs []*prometheus.Desc
+    for desc := range ch {
+        descs = append(descs, desc)
+    }
+    return descs
+}
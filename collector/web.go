@@ -0,0 +1,137 @@
+package collector
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "os/signal"
+    "strconv"
+    "strings"
+    "syscall"
+)
+
+// interfaceListenAddress returns addr with its host replaced by the address
+// of the network interface ifName, for -web.listen-interface. addr must only
+// give the port, e.g. ":9445". IPv4 addresses are preferred over IPv6 ones,
+// and IPv6 link-local addresses are only used as a last resort.
+func interfaceListenAddress(addr, ifName string) (string, error) {
+    host, port, err := net.SplitHostPort(addr)
+    if err != nil {
+        return "", fmt.Errorf("invalid listen address %q: %v", addr, err)
+    }
+    if host != "" {
+        return "", fmt.Errorf("-web.listen-interface can't be combined with the host %q in -web.listen-address, give only the port, e.g. :%s", host, port)
+    }
+
+    iface, err := net.InterfaceByName(ifName)
+    if err != nil {
+        return "", fmt.Errorf("listen interface %q: %v", ifName, err)
+    }
+    if iface.Flags&net.FlagUp == 0 {
+        return "", fmt.Errorf("listen interface %q is down", ifName)
+    }
+    addrs, err := iface.Addrs()
+    if err != nil {
+        return "", fmt.Errorf("listen interface %q: %v", ifName, err)
+    }
+
+    var ipv6, linkLocal string
+    for _, a := range addrs {
+        ipNet, ok := a.(*net.IPNet)
+        if !ok {
+            continue
+        }
+        ip := ipNet.IP
+        switch {
+        case ip.To4() != nil:
+            return net.JoinHostPort(ip.String(), port), nil
+        case ip.IsLinkLocalUnicast():
+            if linkLocal == "" {
+                linkLocal = ip.String() + "%" + ifName
+            }
+        case ipv6 == "":
+            ipv6 = ip.String()
+        }
+    }
+    switch {
+    case ipv6 != "":
+        return net.JoinHostPort(ipv6, port), nil
+    case linkLocal != "":
+        return net.JoinHostPort(linkLocal, port), nil
+    }
+    return "", fmt.Errorf("listen interface %q has no usable address", ifName)
+}
+
+// validateListenAddress checks a -web.listen-address value at startup so a
+// typo fails with a clear message instead of a ListenAndServe error after
+// NVML has been initialized. IPv6 link-local addresses need a zone naming an
+// existing interface, e.g. [fe80::1%eth0]:9445.
+func validateListenAddress(addr string) error {
+    host, port, err := net.SplitHostPort(addr)
+    if err != nil {
+        return fmt.Errorf("invalid listen address %q: %v", addr, err)
+    }
+    if _, err := net.LookupPort("tcp", port); err != nil {
+        return fmt.Errorf("invalid port in listen address %q: %v", addr, err)
+    }
+
+    ip, zone := host, ""
+    if i := strings.LastIndex(host, "%"); i >= 0 {
+        ip, zone = host[:i], host[i+1:]
+    }
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        // A host name, resolved when listening.
+        return nil
+    }
+    isIPv6 := parsed.To4() == nil
+
+    if zone == "" {
+        if isIPv6 && parsed.IsLinkLocalUnicast() {
+            return fmt.Errorf("IPv6 link-local listen address %q needs a zone, e.g. [%s%%eth0]:%s", addr, ip, port)
+        }
+        return nil
+    }
+    if !isIPv6 {
+        return fmt.Errorf("listen address %q has a zone but isn't an IPv6 address", addr)
+    }
+    if _, err := net.InterfaceByName(zone); err == nil {
+        return nil
+    }
+    if index, err := strconv.Atoi(zone); err == nil {
+        if _, err := net.InterfaceByIndex(index); err == nil {
+            return nil
+        }
+    }
+    return fmt.Errorf("listen address %q has zone %q, which isn't a network interface on this host", addr, zone)
+}
+
+// serveAll serves each server on the listener at the same position until one
+// of them fails or SIGINT or SIGTERM arrives, then shuts all of them down.
+func serveAll(servers []*http.Server, listeners []net.Listener) error {
+    errc := make(chan error, len(servers))
+    for i, srv := range servers {
+        go func(srv *http.Server, ln net.Listener) {
+            errc <- srv.Serve(ln)
+        }(srv, listeners[i])
+    }
+
+    sigs := make(chan os.Signal, 1)
+    signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+    var err error
+    select {
+    case sig := <-sigs:
+        log.Printf("Received %v, shutting down", sig)
+    case err = <-errc:
+    }
+
+    for _, srv := range servers {
+        if err := srv.Shutdown(context.Background()); err != nil {
+            log.Printf("Shutdown error: %v", err)
+        }
+    }
+    return err
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// maxAveragingWindows caps -averaging-windows: every window costs two NVML
+// calls per device and scrape.
+const maxAveragingWindows = 4
+
+// averagingWindows are the windows the average metrics are exported for.
+// Set from -averaging-windows, or -averaging-window if that is empty.
+var averagingWindows []time.Duration
+
+// averagingWindowLabels returns the labels of the average metrics. Only
+// -averaging-windows adds the window label, so the series from a single
+// -averaging-window keep the labels they had before windows were added.
+func averagingWindowLabels() []string {
+    if strings.TrimSpace(*averageDurations) == "" {
+        return labels
+    }
+    return withLabels("window")
+}
+
+// averagingWindowLabelValues returns lv with the window label value, if the
+// average metrics have the label.
+func averagingWindowLabelValues(lv []string, window time.Duration) []string {
+    if strings.TrimSpace(*averageDurations) == "" {
+        return lv
+    }
+    return append(lv, window.String())
+}
+
+// parseAveragingWindows parses a comma separated list of durations such as
+// "1s,5s,15s". An empty list means just def.
+func parseAveragingWindows(list string, def time.Duration) ([]time.Duration, error) {
+    if strings.TrimSpace(list) == "" {
+        return []time.Duration{def}, nil
+    }
+    var windows []time.Duration
+    seen := make(map[time.Duration]bool)
+    for _, s := range strings.Split(list, ",") {
+        d, err := time.ParseDuration(strings.TrimSpace(s))
+        if err != nil {
+            return nil, err
+        }
+        if d <= 0 {
+            return nil, fmt.Errorf("window %v must be positive", d)
+        }
+        if !seen[d] {
+            windows = append(windows, d)
+            seen[d] = true
+        }
+    }
+    if len(windows) > maxAveragingWindows {
+        return nil, fmt.Errorf("at most %d windows are allowed, got %d", maxAveragingWindows, len(windows))
+    }
+    return windows, nil
+}
+
+// longestAveragingWindow returns the longest of averagingWindows.
+func longestAveragingWindow() time.Duration {
+    var longest time.Duration
+    for _, w := range averagingWindows {
+        if w > longest {
+            longest = w
+        }
+    }
+    return longest
+}
@@ -4,5 +4,7 @@ go 1.12
 
 require (
 	github.com/cfsmp3/gonvml v0.0.6
+	github.com/coreos/go-systemd/v22 v22.5.0
 	github.com/prometheus/client_golang v1.6.0
+	github.com/prometheus/client_model v0.2.0
 )